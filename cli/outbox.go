@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"microtecture/registry"
+	repository "microtecture/usecase/repositories"
+)
+
+// requeue makes dead events of outbox pending again, ids are all parsed before any event is requeued
+func requeue(ctx context.Context, outbox repository.OutboxRepository, args []string) error {
+	ids := make([]uuid.UUID, 0, len(args))
+	for _, arg := range args {
+		id, err := uuid.Parse(arg)
+		if err != nil {
+			return errors.New("event id " + arg + " is not a uuid.")
+		}
+		ids = append(ids, id)
+	}
+
+	for _, id := range ids {
+		err := outbox.Requeue(ctx, id)
+		if err == repository.ErrOutboxEventNotFound {
+			return errors.New("event " + id.String() + " is not found or is not dead.")
+		}
+		if err != nil {
+			return err
+		}
+		logrus.Infof("Requeued outbox event %s.", id)
+	}
+
+	return nil
+}
+
+var outboxCli = &cobra.Command{
+	Use:   "outbox",
+	Short: "Manage events of outbox.",
+}
+
+var requeueCli = &cobra.Command{
+	Use:   "requeue <event id>...",
+	Short: "Requeue dead events of outbox so relay publishes them again.",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cli *cobra.Command, args []string) error {
+		reg, err := registry.New()
+		if err != nil {
+			errMsg := fmt.Sprintf("%+v\n", err)
+			return fmt.Errorf(errMsg)
+		}
+		app := reg.NewRootController().GetBase().Application
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		return requeue(ctx, app.Outbox, args)
+	},
+}
+
+func init() {
+	outboxCli.AddCommand(requeueCli)
+	rootCli.AddCommand(outboxCli)
+}
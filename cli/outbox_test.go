@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert"
+	"github.com/google/uuid"
+
+	"microtecture/domain/models"
+	"microtecture/infrastructure/datastore"
+)
+
+func TestRequeue(t *testing.T) {
+	ctx := context.Background()
+	outbox := datastore.NewMemoryOutboxRepository()
+
+	dead, err := models.NewOutboxEvent("user", "1", "user.created", map[string]string{"name": "a"})
+	assert.NoError(t, err)
+	assert.NoError(t, outbox.Add(ctx, dead))
+	now := time.Now()
+	dead.Attempts = 10
+	dead.DeadAt = &now
+	assert.NoError(t, outbox.MarkFailed(ctx, dead))
+
+	pending, err := models.NewOutboxEvent("user", "2", "user.created", map[string]string{"name": "b"})
+	assert.NoError(t, err)
+	assert.NoError(t, outbox.Add(ctx, pending))
+
+	assert.EqualError(t, requeue(ctx, outbox, []string{"1"}), "event id 1 is not a uuid.")
+	assert.EqualError(
+		t,
+		requeue(ctx, outbox, []string{pending.Id.String()}),
+		"event "+pending.Id.String()+" is not found or is not dead.",
+	)
+	assert.Error(t, requeue(ctx, outbox, []string{uuid.New().String()}))
+
+	assert.NoError(t, requeue(ctx, outbox, []string{dead.Id.String()}))
+	assert.Error(t, requeue(ctx, outbox, []string{dead.Id.String()}))
+
+	relayed := 0
+	_, err = outbox.Relay(ctx, 10, func(ctx context.Context, events []models.OutboxEvent) error {
+		relayed = len(events)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, relayed)
+}
@@ -3,6 +3,8 @@ package cli
 import (
 	"context"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,38 +13,43 @@ import (
 	"time"
 
 	"github.com/julienschmidt/httprouter"
+	"github.com/nats-io/nats.go"
+	"github.com/rs/cors"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"golang.org/x/net/http2"
 
 	"microtecture/infrastructure/config"
+	"microtecture/infrastructure/discovery"
+	"microtecture/infrastructure/messaging"
+	"microtecture/infrastructure/metrics"
 	"microtecture/interface/router"
 	"microtecture/registry"
 	"microtecture/usecase/controllers"
+	repository "microtecture/usecase/repositories"
 )
 
 func serveAPI(ctx context.Context, controller controllers.Root) {
 	r := httprouter.New()
-
-	// cors config (github.com/rs/cors)
-	//c := cors.New(cors.Options{
-	//	AllowedMethods:   []string{"GET", "POST", "PUT", "HEAD", "PATCH", "DELETE"},
-	//	AllowedHeaders:   []string{"Origin", "Content-Length", "Content-Type", "X-Requested-With", "Authorization"},
-	//	AllowCredentials: true,
-	//	Debug:            true,
-	//	MaxAge:           12 * 60 * 60, // 12 hour
-	//})
-
 	router.Route(r, controller)
 
-	// set cors to handler
-	//handler := c.Handler(r)
+	conf := controller.GetBase().Application.Config
+	var handler http.Handler = r
+	if conf.CORS.Enabled() {
+		c := cors.New(cors.Options{
+			AllowedOrigins:   conf.CORS.AllowedOrigins,
+			AllowedMethods:   conf.CORS.AllowedMethods,
+			AllowedHeaders:   conf.CORS.AllowedHeaders,
+			ExposedHeaders:   conf.CORS.ExposedHeaders,
+			AllowCredentials: conf.CORS.AllowCredentials,
+			MaxAge:           conf.CORS.MaxAge,
+		})
+		handler = c.Handler(r)
+	}
 
 	s := &http.Server{
-		Addr: fmt.Sprintf("%s%d", ":", controller.GetBase().Application.Config.Port),
-		// use cors in server
-		//Handler:           handler,
-		Handler:           r,
+		Addr:              fmt.Sprintf("%s%d", ":", conf.Port),
+		Handler:           handler,
 		ReadTimeout:       10 * time.Second,
 		ReadHeaderTimeout: 10 * time.Second,
 		IdleTimeout:       10 * time.Second,
@@ -56,12 +63,155 @@ func serveAPI(ctx context.Context, controller controllers.Root) {
 		}
 	}()
 
-	logrus.Infof("Serving at http://localhost:%d", controller.GetBase().Application.Config.Port)
+	logrus.Infof("Serving at http://localhost:%d", conf.Port)
+	if err := s.ListenAndServe(); err != http.ErrServerClosed {
+		logrus.Panic(err)
+	}
+}
+
+func serveMetrics(ctx context.Context, controller controllers.Root) {
+	app := controller.GetBase().Application
+	conf := app.Config.Metrics
+
+	mux := http.NewServeMux()
+	mux.Handle(conf.Path, metrics.Handler(metrics.NewRegistry(app.DBSession)))
+
+	s := &http.Server{
+		Addr:              fmt.Sprintf("%s%d", ":", conf.Port),
+		Handler:           mux,
+		ReadTimeout:       10 * time.Second,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		if err := s.Shutdown(context.Background()); err != nil {
+			logrus.Error(err)
+		}
+	}()
+
+	logrus.Infof("Serving metrics at http://localhost:%d%s", conf.Port, conf.Path)
 	if err := s.ListenAndServe(); err != http.ErrServerClosed {
 		logrus.Panic(err)
 	}
 }
 
+func serveGRPC(ctx context.Context, controller controllers.Grpc) {
+	base := controller.GetBase()
+	conf := base.Application.Config.GRPC
+
+	s, err := base.NewServer()
+	if err != nil {
+		logrus.Panic(err)
+	}
+	router.RouteGrpc(s, controller)
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("%s%d", ":", conf.Port))
+	if err != nil {
+		logrus.Panic(err)
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		// streams that do not end keep graceful stop waiting, so they are cancelled after timeout
+		stopped := make(chan struct{})
+		go func() {
+			s.GracefulStop()
+			close(stopped)
+		}()
+
+		timeout := time.Duration(conf.ShutdownTimeout) * time.Second
+		select {
+		case <-stopped:
+		case <-time.After(timeout):
+			logrus.Warningf("grpc calls did not finish in %s, stopping", timeout)
+			s.Stop()
+		}
+	}()
+
+	logrus.Infof("Serving grpc at localhost:%d", conf.Port)
+	if err := s.Serve(listener); err != nil {
+		logrus.Panic(err)
+	}
+}
+
+func serveNats(ctx context.Context, controller controllers.Nats) {
+	if err := router.RouteNats(controller); err != nil {
+		logrus.Panic(err)
+	}
+
+	logrus.Infof("Serving nats at %s", controller.GetBase().Application.Config.NATS.URL)
+	<-ctx.Done()
+
+	if err := controller.GetBase().Drain(); err != nil {
+		logrus.Error(err)
+	}
+}
+
+// newRelay creates relay of outbox, nil if outbox is not enabled, before servers start
+// so that serve fails with its error
+func newRelay(
+	conf config.ApplicationConfig, conn *nats.Conn, outbox repository.OutboxRepository, logger logrus.FieldLogger,
+) (*messaging.Relay, error) {
+	if !conf.Outbox.Enabled {
+		return nil, nil
+	}
+
+	relay, err := messaging.NewRelay(conf, conn, outbox, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	return &relay, nil
+}
+
+// relayOutbox publishes events of outbox to nats until ctx is done
+func relayOutbox(ctx context.Context, relay *messaging.Relay, subject string) {
+	logrus.Infof("Relaying outbox to nats at %s.>", subject)
+	relay.Run(ctx)
+}
+
+// closeApplication closes application after its servers stop, so tracer exports spans
+// that it buffers and nats and sql connections are closed
+func closeApplication(app io.Closer) {
+	if err := app.Close(); err != nil {
+		logrus.Error("Closing application: ", fmt.Sprintf("%+v\n", err))
+	}
+}
+
+// register registers instance of application on discovery registry, nil if discovery is disabled,
+// and returns func that deregisters it, it is safe to call returned func more than once
+func register(conf config.ApplicationConfig, registry discovery.Registry) (func(), error) {
+	if registry == nil {
+		return func() {}, nil
+	}
+
+	instance, err := discovery.Self(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := registry.Register(ctx, instance); err != nil {
+		return nil, err
+	}
+	logrus.Infof("Registered %s at %s", instance.ID, conf.Discovery.Backend)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := registry.Deregister(ctx, instance); err != nil {
+				logrus.Error(err)
+			}
+		})
+	}, nil
+}
+
 var serveCli = &cobra.Command{
 	Use:   "serve",
 	Short: "Serve the application.",
@@ -80,6 +230,22 @@ var serveCli = &cobra.Command{
 			return fmt.Errorf(errMsg)
 		}
 		c := reg.NewRootController()
+		g := reg.NewGrpcController()
+		n := reg.NewNatsController()
+
+		app := c.GetBase().Application
+		relay, err := newRelay(app.Config, app.Nats, app.Outbox, app.Logger)
+		if err != nil {
+			errMsg := fmt.Sprintf("%+v\n", err)
+			return fmt.Errorf(errMsg)
+		}
+
+		deregister, err := register(app.Config, app.Discovery)
+		if err != nil {
+			errMsg := fmt.Sprintf("%+v\n", err)
+			return fmt.Errorf(errMsg)
+		}
+		defer deregister()
 
 		ctx, cancel := context.WithCancel(context.Background())
 
@@ -94,7 +260,19 @@ var serveCli = &cobra.Command{
 				syscall.SIGQUIT,
 			)
 			<-sigint
-			logrus.Info("Signal caught. Shutting down...")
+
+			// peers stop resolving instance while it drains
+			deregister()
+
+			delay := time.Duration(app.Config.Health.ShutdownDelay) * time.Second
+			app.Drain()
+			logrus.Infof("Signal caught. Draining for %s...", delay)
+			select {
+			case <-time.After(delay):
+			case <-sigint:
+			}
+
+			logrus.Info("Shutting down...")
 			cancel()
 		}()
 
@@ -107,7 +285,44 @@ var serveCli = &cobra.Command{
 			serveAPI(ctx, c)
 		}()
 
+		if g.GetBase().Application.Config.GRPC.Enabled() {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer cancel()
+				serveGRPC(ctx, g)
+			}()
+		}
+
+		if n.GetBase().Application.Config.NATS.Enabled() {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer cancel()
+				serveNats(ctx, n)
+			}()
+		}
+
+		if relay != nil {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer cancel()
+				relayOutbox(ctx, relay, app.Config.Outbox.Subject)
+			}()
+		}
+
+		if c.GetBase().Application.Config.Metrics.Enabled() {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer cancel()
+				serveMetrics(ctx, c)
+			}()
+		}
+
 		wg.Wait()
+		closeApplication(app)
 		return nil
 	},
 }
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+
+	"microtecture/infrastructure/config"
+	"microtecture/infrastructure/datastore"
+	"microtecture/infrastructure/discovery"
+)
+
+func TestRegisterAndDeregister(t *testing.T) {
+	conf := config.ApplicationConfig{Port: 8080}
+	conf.Discovery.Host = "10.0.0.1"
+	registry := discovery.NewMemory()
+
+	deregister, err := register(conf, registry)
+	assert.NoError(t, err)
+
+	instances, err := registry.Resolve(context.Background(), config.NAME)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(instances))
+	assert.Equal(t, "10.0.0.1:8080", instances[0].Address())
+
+	deregister()
+	_, err = registry.Resolve(context.Background(), config.NAME)
+	assert.Equal(t, discovery.ErrNoInstance, err)
+
+	// shutdown deregisters on signal and again when serve returns
+	deregister()
+}
+
+func TestRegisterWithoutDiscovery(t *testing.T) {
+	deregister, err := register(config.ApplicationConfig{Port: 8080}, nil)
+	assert.NoError(t, err)
+	deregister()
+}
+
+// testCloser counts its closes and returns err
+type testCloser struct {
+	closed int
+	err    error
+}
+
+func (self *testCloser) Close() error {
+	self.closed++
+	return self.err
+}
+
+func TestCloseApplication(t *testing.T) {
+	hook := test.NewGlobal()
+	t.Cleanup(func() { logrus.StandardLogger().ReplaceHooks(make(logrus.LevelHooks)) })
+
+	app := &testCloser{}
+	closeApplication(app)
+	assert.Equal(t, 1, app.closed)
+	assert.Equal(t, 0, len(hook.AllEntries()))
+
+	app = &testCloser{err: errors.New("tracer shutdown failed")}
+	closeApplication(app)
+	assert.Equal(t, 1, app.closed)
+	assert.Equal(t, logrus.ErrorLevel, hook.LastEntry().Level)
+	assert.Contains(t, hook.LastEntry().Message, "tracer shutdown failed")
+}
+
+func TestNewRelay(t *testing.T) {
+	conf := config.ApplicationConfig{}
+	conf.Outbox.Subject = "test"
+	conf.Outbox.Stream = "TEST"
+
+	// relay is not created while outbox is disabled
+	relay, err := newRelay(conf, nil, datastore.NewMemoryOutboxRepository(), logrus.New())
+	assert.NoError(t, err)
+	assert.Nil(t, relay)
+
+	s, err := server.NewServer(&server.Options{
+		Host:   "127.0.0.1",
+		Port:   server.RANDOM_PORT,
+		NoLog:  true,
+		NoSigs: true,
+	})
+	assert.NoError(t, err)
+	go s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server is not ready")
+	}
+	conn, err := nats.Connect(s.ClientURL())
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		conn.Close()
+		s.Shutdown()
+	})
+
+	// setup errors like nats without jetstream are returned, so serve fails
+	conf.Outbox.Enabled = true
+	relay, err = newRelay(conf, conn, datastore.NewMemoryOutboxRepository(), logrus.New())
+	assert.Error(t, err)
+	assert.Nil(t, relay)
+}
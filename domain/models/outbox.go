@@ -0,0 +1,44 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// OutboxEvent is event of an aggregate that is stored in transaction of its change
+// and published to message bus by relay after transaction commits
+type OutboxEvent struct {
+	Id            uuid.UUID  `gorm:"type:uuid;primary_key" json:"id,omitempty"`
+	Sequence      int64      `gorm:"AUTO_INCREMENT;unique_index" json:"sequence,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt,omitempty"`
+	AggregateType string     `gorm:"type:varchar(64);not null;index:idx_outbox_aggregate" json:"aggregateType,omitempty"`
+	AggregateID   string     `gorm:"type:varchar(64);not null;index:idx_outbox_aggregate" json:"aggregateId,omitempty"`
+	Type          string     `gorm:"type:varchar(128);not null" json:"type,omitempty"`
+	Payload       []byte     `gorm:"type:bytea;not null" json:"payload,omitempty"`
+	Attempts      uint       `gorm:"not null;default:0" json:"attempts"`
+	LastError     string     `gorm:"type:text" json:"lastError,omitempty"`
+	NextAttemptAt time.Time  `gorm:"not null;index" json:"nextAttemptAt,omitempty"`
+	PublishedAt   *time.Time `gorm:"index" json:"publishedAt,omitempty"`
+	DeadAt        *time.Time `gorm:"index" json:"deadAt,omitempty"`
+}
+
+// NewOutboxEvent creates and returns event of type, like user.created,
+// of aggregate with payload as json
+func NewOutboxEvent(aggregateType, aggregateID, eventType string, payload interface{}) (*OutboxEvent, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errors.New(err.Error())
+	}
+
+	return &OutboxEvent{
+		Id:            uuid.New(),
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Type:          eventType,
+		Payload:       data,
+		NextAttemptAt: time.Now(),
+	}, nil
+}
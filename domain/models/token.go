@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken is issued refresh token, every refresh rotates it to a new one of same family
+type RefreshToken struct {
+	Id        uuid.UUID  `gorm:"type:uuid;primary_key" json:"id,omitempty"`
+	CreatedAt time.Time  `json:"createdAt,omitempty"`
+	FamilyID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"familyId,omitempty"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"userId,omitempty"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expiresAt,omitempty"`
+	UsedAt    *time.Time `json:"usedAt,omitempty"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
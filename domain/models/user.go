@@ -14,12 +14,12 @@ import (
 type User struct {
 	Id           uuid.UUID  `gorm:"type:uuid;primary_key" json:"id,omitempty"`
 	CreatedAt    time.Time  `json:"createdAt,omitempty"`
-	UpdatedAt    time.Time  `json:"createdAt,omitempty"`
+	UpdatedAt    time.Time  `json:"updatedAt,omitempty"`
 	DeletedAt    *time.Time `sql:"index" json:"deletedId,omitempty"`
 	Password     []byte     `gorm:"not null" json:"password,omitempty"`
-	MobileNumber string     `gorm:"type:varchar(11);unique_index" json:"mobileNumber,omitempty"`
-	FirstName    string     `gorm:"type:varchar(64);index" json:"firstName,omitempty"`
-	LastName     string     `gorm:"type:varchar(64);index" json:"lastName,omitempty"`
+	MobileNumber string     `gorm:"type:varchar(11);unique_index" json:"mobileNumber,omitempty" validate:"required,mobile"`
+	FirstName    string     `gorm:"type:varchar(64);index" json:"firstName,omitempty" validate:"max=64"`
+	LastName     string     `gorm:"type:varchar(64);index" json:"lastName,omitempty" validate:"max=64"`
 
 	GroupID uuid.UUID `gorm:"type:uuid;not null" json:"groupId,omitempty"`
 	Group   Group
@@ -29,8 +29,8 @@ type User struct {
 type Group struct {
 	Id          uuid.UUID  `gorm:"type:uuid;priamry_key" json:"id,omitempty"`
 	DeletedAt   *time.Time `sql:"index" json:"deletedAt,omitempty"`
-	Name        string     `gorm:"type:varchar(64);not null;unique;" json:"name,omitempty"`
-	Description string     `gorm:"type:varchar(256);not null" json:"description,omitempty"`
+	Name        string     `gorm:"type:varchar(64);not null;unique;" json:"name,omitempty" validate:"required,max=64"`
+	Description string     `gorm:"type:varchar(256);not null" json:"description,omitempty" validate:"required,max=256"`
 
 	Roles []Role `gorm:"many2many:groups_roles" json:"roles,omitempty"`
 	Users []User
@@ -40,16 +40,41 @@ type Group struct {
 type Role struct {
 	Id        uuid.UUID  `gorm:"type:uuid;priamry_key" json:"id,omitempty"`
 	DeletedAt *time.Time `sql:"index" json:"deletedAt,omitempty"`
-	FaName    string     `gorm:"not null; type:varchar(64);unique" json:"faName,omitempty"`
-	EnName    string     `gorm:"not null; type:varchar(64);unique" json:"enName,omitempty"`
+	FaName    string     `gorm:"not null; type:varchar(64);unique" json:"faName,omitempty" validate:"required,max=64"`
+	EnName    string     `gorm:"not null; type:varchar(64);unique" json:"enName,omitempty" validate:"required,max=64"`
 
-	Groups []Group `gorm:"many2many:groups_roles" json:"groups,omitempty"`
+	Groups      []Group      `gorm:"many2many:groups_roles" json:"groups,omitempty"`
+	Permissions []Permission `gorm:"many2many:roles_permissions" json:"permissions,omitempty"`
+}
+
+// Permission is named action that roles grant, like user:read
+type Permission struct {
+	Id          uuid.UUID `gorm:"type:uuid;primary_key" json:"id,omitempty"`
+	Name        string    `gorm:"type:varchar(64);not null;unique" json:"name,omitempty" validate:"required,max=64,regex=^[a-z_]+:[a-z_]+$"`
+	Description string    `gorm:"type:varchar(256)" json:"description,omitempty" validate:"max=256"`
+
+	Roles []Role `gorm:"many2many:roles_permissions" json:"roles,omitempty"`
+}
+
+// HashPassword returns bcrypt hash of password
+func HashPassword(password []byte) ([]byte, error) {
+	hashed, err := bcrypt.GenerateFromPassword(password, bcrypt.DefaultCost)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Failed to password hashing: %v", err))
+	}
+
+	return hashed, nil
+}
+
+// CheckPassword reports whether password matches hashed password of user
+func (self User) CheckPassword(password []byte) bool {
+	return bcrypt.CompareHashAndPassword(self.Password, password) == nil
 }
 
 func (self *User) BeforeCreate(scope *gorm.Scope) (err error) {
-	hashed, err := bcrypt.GenerateFromPassword(self.Password, bcrypt.DefaultCost)
+	hashed, err := HashPassword(self.Password)
 	if err != nil {
-		return errors.New(fmt.Sprintf("Failed to password hashing: %v", err))
+		return err
 	}
 
 	err = scope.SetColumn("Password", hashed)
@@ -59,3 +84,7 @@ func (self *User) BeforeCreate(scope *gorm.Scope) (err error) {
 
 	return scope.SetColumn("ID", uuid.New())
 }
+
+func (self *Permission) BeforeCreate(scope *gorm.Scope) error {
+	return scope.SetColumn("ID", uuid.New())
+}
@@ -1,9 +1,18 @@
 package application
 
 import (
+	"net"
+	"time"
+
 	"microtecture/infrastructure/config"
 	"microtecture/infrastructure/datastore"
+	"microtecture/infrastructure/discovery"
+	"microtecture/infrastructure/messaging"
+	"microtecture/infrastructure/tracing"
+	repository "microtecture/usecase/repositories"
 
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
@@ -11,6 +20,20 @@ type application struct {
 	Config    config.ApplicationConfig
 	DBSession datastore.Session
 	Logger    logrus.FieldLogger
+	Nats      *nats.Conn
+	Discovery discovery.Registry
+	Users     repository.UserRepository
+
+	RefreshTokens repository.RefreshTokenRepository
+	Roles         repository.RoleRepository
+	Outbox        repository.OutboxRepository
+	Transactions  repository.Transactor
+
+	keys           keySet
+	permissions    *permissionCache
+	trustedProxies []*net.IPNet
+	tracer         *tracing.Provider
+	health         *healthChecker
 }
 
 // New creates and returns Application
@@ -24,6 +47,32 @@ func New() (application, error) {
 	appConfig := conf.(*config.ApplicationConfig)
 	app.Config = *appConfig
 
+	keys, err := newKeySet(app.Config)
+	if err != nil {
+		return app, err
+	}
+	app.keys = keys
+	app.permissions = newPermissionCache(time.Duration(*app.Config.PermissionCacheTTL) * time.Second)
+	app.health = newHealthChecker(
+		time.Duration(app.Config.Health.CacheTTL)*time.Second,
+		time.Duration(app.Config.Health.Timeout)*time.Second,
+	)
+
+	for _, cidr := range app.Config.TrustedProxies {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return app, errors.New(err.Error())
+		}
+		app.trustedProxies = append(app.trustedProxies, network)
+	}
+
+	// tracer provider is set before sessions so their spans are exported
+	tracer, err := tracing.NewProvider(app.Config)
+	if err != nil {
+		return app, err
+	}
+	app.tracer = tracer
+
 	dbSession, err := datastore.NewSession()
 	if err != nil {
 		return app, err
@@ -31,10 +80,39 @@ func New() (application, error) {
 
 	app.DBSession = *dbSession
 
+	app.Discovery, err = discovery.New(app.Config)
+	if err != nil {
+		return app, err
+	}
+
+	if app.Config.NATS.Enabled() {
+		conn, err := messaging.Connect(app.Config)
+		if err != nil {
+			return app, err
+		}
+		app.Nats = conn
+	}
+
 	return app, nil
 }
 
-// Close close sql database of application and some things else
+// Close exports buffered spans and closes nats and sql database of application,
+// everything is closed even if one fails and first error is returned
 func (self application) Close() error {
-	return self.DBSession.SQLSession.Close()
+	var err error
+	if self.tracer != nil {
+		err = self.tracer.Shutdown()
+	}
+
+	if self.Nats != nil {
+		self.Nats.Close()
+	}
+
+	if self.DBSession.SQLSession.DB != nil {
+		if closeErr := self.DBSession.SQLSession.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	return err
 }
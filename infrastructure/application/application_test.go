@@ -0,0 +1,71 @@
+package application
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"microtecture/domain/models"
+	"microtecture/infrastructure/config"
+	"microtecture/infrastructure/datastore"
+)
+
+// newTestApplication returns application with hmac keys and memory repositories,
+// it needs no config file or database
+func newTestApplication(t *testing.T, groups ...models.Group) application {
+	grace := uint(10)
+	conf := config.ApplicationConfig{}
+	conf.SecretKey = "0123456789abcdef0123456789abcdef"
+	conf.JWT.Algorithm = config.HS256
+	conf.JWT.Secret = "access-secret"
+	conf.JWT.MaxAge = 900
+	conf.JWT.Path = "/"
+	conf.JWT.TokenSources = []string{config.TOKEN_SOURCE_COOKIE, config.TOKEN_SOURCE_HEADER}
+	conf.JWT.RefreshToken.Secret = "refresh-secret"
+	conf.JWT.RefreshToken.Algorithm = config.HS256
+	conf.JWT.RefreshToken.MaxAge = 86400
+	conf.JWT.RefreshToken.Path = "/"
+	conf.JWT.RefreshToken.ReuseGrace = &grace
+
+	keys, err := newKeySet(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.PanicLevel)
+
+	return application{
+		Config:        conf,
+		Logger:        logger,
+		Users:         datastore.NewMemoryUserRepository(groups...),
+		RefreshTokens: datastore.NewMemoryRefreshTokenRepository(),
+		Roles:         datastore.NewMemoryRoleRepository(nil),
+		keys:          keys,
+		permissions:   newPermissionCache(time.Minute),
+		health:        newHealthChecker(time.Second, time.Second),
+	}
+}
+
+// newTestContext returns context of request that records its response
+func newTestContext(app application, request *http.Request) (*Context, *httptest.ResponseRecorder) {
+	recorder := httptest.NewRecorder()
+	ctx := NewContext().WithRequest(request).WithResponseWriter(&statusCodeRecorder{ResponseWriter: recorder})
+	ctx.WithLogger(app.Logger)
+
+	return ctx, recorder
+}
+
+// cookieOf returns value of cookie that response sets
+func cookieOf(recorder *httptest.ResponseRecorder, name string) string {
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie.Value
+		}
+	}
+
+	return ""
+}
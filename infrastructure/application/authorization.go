@@ -1,8 +1,10 @@
 package application
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
@@ -11,16 +13,34 @@ import (
 
 	"microtecture/domain/models"
 	"microtecture/infrastructure/config"
+	"microtecture/infrastructure/metrics"
+	repository "microtecture/usecase/repositories"
+)
+
+const (
+	BEARER_INVALID_REQUEST = "invalid_request"
+	BEARER_INVALID_TOKEN   = "invalid_token"
 )
 
 var (
-	jwtSigningMethods = make(map[string]*jwt.SigningMethodHMAC)
+	jwtSigningMethods = make(map[string]jwt.SigningMethod)
 )
 
 func init() {
 	jwtSigningMethods[config.HS256] = jwt.SigningMethodHS256
 	jwtSigningMethods[config.HS384] = jwt.SigningMethodHS384
 	jwtSigningMethods[config.HS512] = jwt.SigningMethodHS512
+	jwtSigningMethods[config.RS256] = jwt.SigningMethodRS256
+	jwtSigningMethods[config.RS384] = jwt.SigningMethodRS384
+	jwtSigningMethods[config.RS512] = jwt.SigningMethodRS512
+	jwtSigningMethods[config.ES256] = jwt.SigningMethodES256
+	jwtSigningMethods[config.ES384] = jwt.SigningMethodES384
+	jwtSigningMethods[config.ES512] = jwt.SigningMethodES512
+	jwtSigningMethods[config.EDDSA] = signingMethodEdDSA{}
+
+	jwt.RegisterSigningMethod(config.EDDSA, func() jwt.SigningMethod {
+		return signingMethodEdDSA{}
+	})
 }
 
 // Claims is jwt claims
@@ -50,8 +70,11 @@ func (self application) CreateJWT(userid uuid.UUID, firstName string, lastName s
 		},
 	}
 
-	token := jwt.NewWithClaims(jwtSigningMethods[self.Config.JWT.Algorithm], claims)
-	tokenString, err := token.SignedString([]byte(self.Config.JWT.Secret))
+	token := jwt.NewWithClaims(self.keys.signing.method, claims)
+	if self.keys.signing.kid != "" {
+		token.Header["kid"] = self.keys.signing.kid
+	}
+	tokenString, err := token.SignedString(self.keys.signing.privateKey)
 	if err != nil {
 		return "", errors.New(err.Error())
 	}
@@ -59,13 +82,24 @@ func (self application) CreateJWT(userid uuid.UUID, firstName string, lastName s
 	return tokenString, nil
 }
 
-//CreateRefreshToken creates refresh token
-func (self application) CreateRefreshToken(userid uuid.UUID) (string, error) {
+//CreateRefreshToken creates refresh token of a new token family
+func (self application) CreateRefreshToken(ctx context.Context, userid uuid.UUID) (string, error) {
+	return self.createRefreshToken(ctx, userid, uuid.New())
+}
+
+func (self application) createRefreshToken(ctx context.Context, userid uuid.UUID, familyID uuid.UUID) (string, error) {
 	expirationTime := time.Now().Add(time.Duration(self.Config.JWT.RefreshToken.MaxAge) * time.Second)
+	stored := &models.RefreshToken{
+		Id:        uuid.New(),
+		FamilyID:  familyID,
+		UserID:    userid,
+		ExpiresAt: expirationTime,
+	}
 	claims := Claims{
 		Id:    userid,
 		Roles: nil,
 		StandardClaims: jwt.StandardClaims{
+			Id:        stored.Id.String(),
 			ExpiresAt: expirationTime.Unix(),
 		},
 	}
@@ -73,79 +107,172 @@ func (self application) CreateRefreshToken(userid uuid.UUID) (string, error) {
 	token := jwt.NewWithClaims(
 		jwtSigningMethods[self.Config.JWT.RefreshToken.Algorithm], claims,
 	)
-	tokenString, err := token.SignedString(self.Config.JWT.RefreshToken.Secret)
+	tokenString, err := token.SignedString([]byte(self.Config.JWT.RefreshToken.Secret))
 	if err != nil {
 		return "", errors.New(err.Error())
 	}
 
+	if err := self.RefreshTokens.Create(ctx, stored); err != nil {
+		return "", err
+	}
+
 	return tokenString, nil
 }
 
-// Authorize checks user authorization
-func (self application) Authorize(f action, roles ...string) action {
+// Authorize checks user authorization, every policy must permit the user, like
+// Authorize(f, AnyRole("admin")) for roles or Authorize(f, AllPermissions("user:read", "user:write"))
+// and Authorize(f, AnyPermission("user:read", "user:admin")) for permissions
+func (self application) Authorize(f Action, policies ...Policy) Action {
 	return func(ctx *Context) error {
-		tokenString, err := ctx.ReadCookie(config.ACCESS_TOKEN_NAME)
-		if err != nil && err != http.ErrNoCookie {
-			self.Logger.Error(fmt.Sprintf("%+v\n", err))
-			return NewErrUnauthorized()
+		if _, err := self.authenticate(ctx); err != nil {
+			metrics.ObserveAuth(metrics.AUTH_UNAUTHORIZED)
+			return err
 		}
 
-		if err == http.ErrNoCookie {
-			tokenString = ctx.Request.Header.Get(config.AUTHORZIATION_NAME)
-			if tokenString == "" {
-				return NewErrUnauthorized()
-			}
+		if err := permit(ctx, policies); err != nil {
+			return err
 		}
 
-		keyfunc := func(token *jwt.Token) (interface{}, error) {
-			return self.Config.JWT.Secret, nil
+		return f(ctx)
+	}
+}
+
+// permit checks that every policy permits principal of context and counts outcome,
+// Authorize of rest and grpc controllers share it
+func permit(ctx *Context, policies []Policy) error {
+	for _, policy := range policies {
+		permitted, err := policy(ctx)
+		if err != nil {
+			return err
+		}
+		if !permitted {
+			metrics.ObserveAuth(metrics.AUTH_FORBIDDEN)
+			return NewErrForbidden()
+		}
+	}
+	metrics.ObserveAuth(metrics.AUTH_SUCCESS)
+
+	return nil
+}
+
+// authenticate verifies access token of request, refreshes it if expired
+// and attaches its principal to context
+func (self application) authenticate(ctx *Context) (*Principal, error) {
+	tokenString, err := self.readAccessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if tokenString == "" {
+		return nil, self.unauthorized(ctx, "", "")
+	}
+
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, self.keys.verificationKey)
+	if err != nil && !isExpired(err) {
+		ctx.Logger.Error(fmt.Sprintf("%+v\n", err))
+		return nil, self.unauthorized(ctx, BEARER_INVALID_TOKEN, "The access token is invalid")
+	}
+
+	if !token.Valid {
+		user, tokens, err := self.RefreshToken(ctx)
+		if _, ok := err.(ErrUnauthorized); ok {
+			return nil, self.unauthorized(ctx, BEARER_INVALID_TOKEN, "The access token expired")
 		}
-		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, keyfunc)
 		if err != nil {
-			self.Logger.Error(fmt.Sprintf("%+v\n", err))
-			return NewErrUnauthorized()
+			ctx.Logger.Error(fmt.Sprintf("%+v\n", err))
+			return nil, self.unauthorized(ctx, BEARER_INVALID_TOKEN, "The access token expired")
 		}
+		claims.Id = user.Id
+		claims.FirstName = user.FirstName
+		claims.LastName = user.LastName
+		claims.Roles = userRoles(user)
+		tokenString = tokens.AccessToken
+		metrics.ObserveAuth(metrics.AUTH_REFRESHED)
+	}
+
+	principal := self.newPrincipal(ctx.Request.Context(), claims)
+	principal.Token = tokenString
+	ctx.WithPrincipal(principal).WithLogger(ctx.Logger.WithField("user_id", principal.Id))
+	ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), principalKey{}, principal))
+	ctx.tracePrincipal(principal)
+
+	return principal, nil
+}
 
-		if !token.Valid {
-			roles, err := self.RefreshToken(ctx)
-			if err == NewErrUnauthorized() {
-				return err
+// readAccessToken reads access token from configured token sources in order,
+// returns empty string if request has no token
+func (self application) readAccessToken(ctx *Context) (string, error) {
+	for _, source := range self.Config.JWT.TokenSources {
+		switch source {
+		case config.TOKEN_SOURCE_COOKIE:
+			token, err := ctx.ReadCookie(config.ACCESS_TOKEN_NAME)
+			if err == http.ErrNoCookie {
+				continue
 			}
 			if err != nil {
-				self.Logger.Error(fmt.Sprintf("%+v\n", err))
-				return NewErrUnauthorized()
+				ctx.Logger.Error(fmt.Sprintf("%+v\n", err))
+				return "", self.invalidRequest(ctx, "The access token cookie is malformed")
 			}
-			claims.Roles = roles
-		}
-
-		doNext := false
-		extBreak := false
-		if len(roles) > 0 {
-			for _, role := range roles {
-				for _, userRole := range claims.Roles {
-					if role == userRole {
-						doNext = true
-						extBreak = true
-						break
-					}
-				}
-				if extBreak {
-					break
-				}
+			return token, nil
+		case config.TOKEN_SOURCE_HEADER:
+			header := ctx.Request.Header.Get(config.AUTHORZIATION_NAME)
+			if header == "" {
+				continue
 			}
-			if !doNext {
-				return NewErrForbidden()
+			token, ok := parseBearer(header)
+			if !ok {
+				return "", self.invalidRequest(ctx, "The Authorization header is not Bearer")
 			}
+			return token, nil
+		case config.TOKEN_SOURCE_QUERY:
+			token := ctx.Request.URL.Query().Get(self.Config.JWT.QueryParam)
+			if token == "" {
+				continue
+			}
+			return token, nil
 		}
+	}
 
-		return f(ctx)
+	return "", nil
+}
+
+// parseBearer returns token of Authorization header value with Bearer scheme of RFC 6750
+func parseBearer(header string) (string, bool) {
+	const scheme = "bearer "
+	if len(header) <= len(scheme) || !strings.EqualFold(header[:len(scheme)], scheme) {
+		return "", false
 	}
+
+	token := strings.TrimSpace(header[len(scheme):])
+	return token, token != ""
+}
+
+// unauthorized sets WWW-Authenticate challenge of RFC 6750 and returns ErrUnauthorized,
+// code and description are empty when request has no token
+func (self application) unauthorized(ctx *Context, code, description string) error {
+	challenge(ctx, code, description)
+
+	return NewErrUnauthorized()
+}
+
+// invalidRequest sets WWW-Authenticate challenge of RFC 6750 and returns bad request,
+// as section 3.1 answers invalid_request with 400
+func (self application) invalidRequest(ctx *Context, description string) error {
+	challenge(ctx, BEARER_INVALID_REQUEST, description)
+
+	return NewErrCustom(http.StatusBadRequest, description).WithCode(BEARER_INVALID_REQUEST)
 }
 
-// RefreshToken refreshes token
-// return roles and error
-func (self application) RefreshToken(ctx *Context) ([]string, error) {
+func challenge(ctx *Context, code, description string) {
+	value := fmt.Sprintf(`Bearer realm="%s"`, config.NAME)
+	if code != "" {
+		value += fmt.Sprintf(`, error="%s", error_description="%s"`, code, description)
+	}
+	ctx.Response.Header().Set("WWW-Authenticate", value)
+}
+
+// readRefreshToken reads and verifies refresh token of request and returns its stored record
+func (self application) readRefreshToken(ctx *Context) (*models.RefreshToken, error) {
 	tokenString, err := ctx.ReadCookie(config.REFRESH_TOKEN_NAME)
 	if err != nil && err != http.ErrNoCookie {
 		return nil, errors.New(err.Error())
@@ -154,15 +281,18 @@ func (self application) RefreshToken(ctx *Context) ([]string, error) {
 	if err == http.ErrNoCookie {
 		tokenString = ctx.Request.Header.Get(config.REFRESH_TOKEN_NAME)
 		if tokenString == "" {
-			return nil, errors.New("refresh token not in cookie or header")
+			return nil, NewErrUnauthorized()
 		}
 	}
 
 	keyfunc := func(token *jwt.Token) (interface{}, error) {
-		return self.Config.JWT.RefreshToken.Secret, nil
+		return []byte(self.Config.JWT.RefreshToken.Secret), nil
 	}
 	claims := &Claims{}
 	token, err := jwt.ParseWithClaims(tokenString, claims, keyfunc)
+	if _, ok := err.(*jwt.ValidationError); ok {
+		return nil, NewErrUnauthorized()
+	}
 	if err != nil {
 		return nil, errors.New(err.Error())
 	}
@@ -171,21 +301,123 @@ func (self application) RefreshToken(ctx *Context) ([]string, error) {
 		return nil, NewErrUnauthorized()
 	}
 
-	user := models.User{Id: claims.Id}
-	// TODO: get user from database
-
-	roles := make([]string, len(user.Group.Roles))
-	for i, role := range user.Group.Roles {
-		roles[i] = role.EnName
+	jti, err := uuid.Parse(claims.StandardClaims.Id)
+	if err != nil {
+		return nil, NewErrUnauthorized()
 	}
 
-	jwt, err := self.CreateJWT(user.Id, user.FirstName, user.LastName, false, roles...)
+	stored, err := self.RefreshTokens.FindByID(ctx.Request.Context(), jti)
+	if err == repository.ErrRefreshTokenNotFound {
+		return nil, NewErrUnauthorized()
+	}
 	if err != nil {
 		return nil, err
 	}
+	if stored.RevokedAt != nil || stored.UserID != claims.Id {
+		return nil, NewErrUnauthorized()
+	}
+
+	return stored, nil
+}
+
+// RefreshToken rotates refresh token, refreshes access token and loads its user from database.
+// presenting an already rotated refresh token revokes its whole family, unless it was rotated
+// within reuse grace by a concurrent request, then only access token is refreshed.
+// return user, new tokens and error
+func (self application) RefreshToken(ctx *Context) (*models.User, Tokens, error) {
+	stored, err := self.readRefreshToken(ctx)
+	if err != nil {
+		return nil, Tokens{}, err
+	}
 
-	ctx.SetCookie(config.ACCESS_TOKEN_NAME, jwt, self.Config.JWT.MaxAge)
-	ctx = ctx.WithUser(&user)
+	rotate := true
+	err = self.RefreshTokens.Use(ctx.Request.Context(), stored.Id)
+	if err == repository.ErrRefreshTokenReused {
+		graced, err := self.inReuseGrace(ctx.Request.Context(), stored.Id)
+		if err != nil {
+			return nil, Tokens{}, err
+		}
+		if !graced {
+			ctx.Logger.Warning(
+				fmt.Sprintf("refresh token %s of user %s reused, revoking its family", stored.Id, stored.UserID),
+			)
+			if err := self.RefreshTokens.RevokeFamily(ctx.Request.Context(), stored.FamilyID); err != nil {
+				return nil, Tokens{}, err
+			}
+			return nil, Tokens{}, NewErrUnauthorized()
+		}
+		rotate = false
+	} else if err != nil {
+		return nil, Tokens{}, err
+	}
+
+	user, err := self.Users.FindByID(ctx.Request.Context(), stored.UserID)
+	if err == repository.ErrUserNotFound {
+		return nil, Tokens{}, NewErrUnauthorized()
+	}
+	if err != nil {
+		return nil, Tokens{}, err
+	}
+	if user.DeletedAt != nil {
+		return nil, Tokens{}, NewErrUnauthorized()
+	}
+
+	var tokens Tokens
+	if rotate {
+		tokens, err = self.issueTokens(ctx, user, stored.FamilyID)
+	} else {
+		tokens, err = self.issueAccessToken(ctx, user)
+	}
+	if err != nil {
+		return nil, Tokens{}, err
+	}
+	ctx.WithUser(user)
+
+	return user, tokens, nil
+}
+
+// inReuseGrace reports whether refresh token was rotated within reuse grace of config
+func (self application) inReuseGrace(ctx context.Context, id uuid.UUID) (bool, error) {
+	grace := time.Duration(*self.Config.JWT.RefreshToken.ReuseGrace) * time.Second
+	if grace == 0 {
+		return false, nil
+	}
+
+	stored, err := self.RefreshTokens.FindByID(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	return stored.UsedAt != nil && time.Since(*stored.UsedAt) <= grace, nil
+}
+
+// Logout clears token cookies and revokes refresh token of request and every token rotated from same login
+func (self application) Logout(ctx *Context) error {
+	self.clearTokenCookies(ctx)
+
+	stored, err := self.readRefreshToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	return self.RefreshTokens.RevokeFamily(ctx.Request.Context(), stored.FamilyID)
+}
+
+// LogoutAll revokes every refresh token of user so all of its sessions end on next refresh
+func (self application) LogoutAll(ctx context.Context, userID uuid.UUID) error {
+	return self.RefreshTokens.RevokeUser(ctx, userID)
+}
+
+func userRoles(user *models.User) []string {
+	roles := make([]string, len(user.Group.Roles))
+	for i, role := range user.Group.Roles {
+		roles[i] = role.EnName
+	}
+
+	return roles
+}
 
-	return roles, nil
+func isExpired(err error) bool {
+	ve, ok := err.(*jwt.ValidationError)
+	return ok && ve.Errors == jwt.ValidationErrorExpired
 }
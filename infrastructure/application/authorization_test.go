@@ -0,0 +1,267 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert"
+
+	"microtecture/domain/models"
+	"microtecture/infrastructure/config"
+)
+
+func createTestUser(t *testing.T, app application) *models.User {
+	user := &models.User{Password: []byte("password"), MobileNumber: "09120000000"}
+	if err := app.Users.Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+
+	return user
+}
+
+func refreshRequest(refreshToken string) *http.Request {
+	request := httptest.NewRequest(http.MethodPost, "/api/v1/auth/refresh", nil)
+	request.AddCookie(&http.Cookie{Name: config.REFRESH_TOKEN_NAME, Value: refreshToken})
+
+	return request
+}
+
+func TestRefreshTokenRotates(t *testing.T) {
+	app := newTestApplication(t)
+	user := createTestUser(t, app)
+	refreshToken, err := app.CreateRefreshToken(context.Background(), user.Id)
+	assert.NoError(t, err)
+
+	ctx, recorder := newTestContext(app, refreshRequest(refreshToken))
+	refreshed, tokens, err := app.RefreshToken(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, user.Id, refreshed.Id)
+	assert.NotEqual(t, "", tokens.AccessToken)
+	assert.NotEqual(t, "", cookieOf(recorder, config.REFRESH_TOKEN_NAME))
+	assert.NotEqual(t, refreshToken, cookieOf(recorder, config.REFRESH_TOKEN_NAME))
+}
+
+func TestRefreshTokenConcurrentReuseIsGraced(t *testing.T) {
+	app := newTestApplication(t)
+	user := createTestUser(t, app)
+	refreshToken, err := app.CreateRefreshToken(context.Background(), user.Id)
+	assert.NoError(t, err)
+
+	ctx, first := newTestContext(app, refreshRequest(refreshToken))
+	_, _, err = app.RefreshToken(ctx)
+	assert.NoError(t, err)
+	rotated := cookieOf(first, config.REFRESH_TOKEN_NAME)
+
+	// second request of client carries same refresh token before it sees rotated one
+	ctx, second := newTestContext(app, refreshRequest(refreshToken))
+	_, tokens, err := app.RefreshToken(ctx)
+	assert.NoError(t, err)
+	assert.NotEqual(t, "", tokens.AccessToken)
+	assert.Equal(t, "", cookieOf(second, config.REFRESH_TOKEN_NAME))
+
+	// family is not revoked, so rotated token still refreshes
+	ctx, _ = newTestContext(app, refreshRequest(rotated))
+	_, _, err = app.RefreshToken(ctx)
+	assert.NoError(t, err)
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	app := newTestApplication(t)
+	grace := uint(0)
+	app.Config.JWT.RefreshToken.ReuseGrace = &grace
+	user := createTestUser(t, app)
+	refreshToken, err := app.CreateRefreshToken(context.Background(), user.Id)
+	assert.NoError(t, err)
+
+	ctx, first := newTestContext(app, refreshRequest(refreshToken))
+	_, _, err = app.RefreshToken(ctx)
+	assert.NoError(t, err)
+	rotated := cookieOf(first, config.REFRESH_TOKEN_NAME)
+
+	ctx, _ = newTestContext(app, refreshRequest(refreshToken))
+	_, _, err = app.RefreshToken(ctx)
+	assert.IsType(t, ErrUnauthorized{}, err)
+
+	ctx, _ = newTestContext(app, refreshRequest(rotated))
+	_, _, err = app.RefreshToken(ctx)
+	assert.IsType(t, ErrUnauthorized{}, err)
+}
+
+func TestRefreshTokenReuseAfterGraceRevokesFamily(t *testing.T) {
+	app := newTestApplication(t)
+	user := createTestUser(t, app)
+	refreshToken, err := app.CreateRefreshToken(context.Background(), user.Id)
+	assert.NoError(t, err)
+
+	ctx, _ := newTestContext(app, refreshRequest(refreshToken))
+	_, _, err = app.RefreshToken(ctx)
+	assert.NoError(t, err)
+
+	grace := uint(1)
+	app.Config.JWT.RefreshToken.ReuseGrace = &grace
+	time.Sleep(1100 * time.Millisecond)
+
+	ctx, _ = newTestContext(app, refreshRequest(refreshToken))
+	_, _, err = app.RefreshToken(ctx)
+	assert.IsType(t, ErrUnauthorized{}, err)
+}
+
+func TestTokensAreNotMarshaled(t *testing.T) {
+	data, err := json.Marshal(Tokens{AccessToken: "access", RefreshToken: "refresh", ExpiresIn: 900})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"expiresIn":900}`, string(data))
+}
+
+func TestLoginSessionHasTokensForHeaderSource(t *testing.T) {
+	app := newTestApplication(t)
+	app.Config.JWT.TokenSources = []string{config.TOKEN_SOURCE_COOKIE, config.TOKEN_SOURCE_HEADER}
+	user := createTestUser(t, app)
+
+	ctx, recorder := newTestContext(app, httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", nil))
+	tokens, err := app.Login(ctx, user)
+	assert.NoError(t, err)
+	session := app.NewSession(user, tokens)
+	assert.Equal(t, cookieOf(recorder, config.ACCESS_TOKEN_NAME), session.AccessToken)
+	assert.Equal(t, cookieOf(recorder, config.REFRESH_TOKEN_NAME), session.RefreshToken)
+	assert.Equal(t, []byte(nil), session.User.Password)
+
+	// header clients send access token as Bearer and refresh token in its header
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+	request.Header.Set(config.AUTHORZIATION_NAME, "Bearer "+session.AccessToken)
+	ctx, _ = newTestContext(app, request)
+	principal, err := app.authenticate(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, user.Id, principal.Id)
+
+	request = httptest.NewRequest(http.MethodPost, "/api/v1/auth/refresh", nil)
+	request.Header.Set(config.REFRESH_TOKEN_NAME, session.RefreshToken)
+	ctx, _ = newTestContext(app, request)
+	_, tokens, err = app.RefreshToken(ctx)
+	assert.NoError(t, err)
+	assert.NotEqual(t, "", app.NewSession(user, tokens).AccessToken)
+}
+
+func TestLoginSessionHasNoTokensForCookieSource(t *testing.T) {
+	app := newTestApplication(t)
+	app.Config.JWT.TokenSources = []string{config.TOKEN_SOURCE_COOKIE}
+	user := createTestUser(t, app)
+
+	ctx, recorder := newTestContext(app, httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", nil))
+	tokens, err := app.Login(ctx, user)
+	assert.NoError(t, err)
+	assert.NotEqual(t, "", cookieOf(recorder, config.ACCESS_TOKEN_NAME))
+
+	data, err := json.Marshal(app.NewSession(user, tokens))
+	assert.NoError(t, err)
+	body := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(data, &body))
+	assert.Equal(t, float64(900), body["expiresIn"])
+	for _, name := range []string{"accessToken", "refreshToken"} {
+		_, ok := body[name]
+		assert.False(t, ok, name)
+	}
+	assert.NotZero(t, body["user"])
+}
+
+func TestParseBearer(t *testing.T) {
+	cases := []struct {
+		header string
+		token  string
+		ok     bool
+	}{
+		{"Bearer abc", "abc", true},
+		{"bearer abc", "abc", true},
+		{"BEARER abc", "abc", true},
+		{"Bearer   abc  ", "abc", true},
+		{"Bearer ", "", false},
+		{"Bearer    ", "", false},
+		{"Bearer", "", false},
+		{"Bearerabc", "", false},
+		{"Basic YWJjOmRlZg==", "", false},
+		{"", "", false},
+	}
+	for _, c := range cases {
+		token, ok := parseBearer(c.header)
+		assert.Equal(t, c.token, token, c.header)
+		assert.Equal(t, c.ok, ok, c.header)
+	}
+}
+
+// tokenRequest returns request that carries access token cookie, header and query parameter
+func tokenRequest(app application) *http.Request {
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/users?"+app.Config.JWT.QueryParam+"=query", nil)
+	request.AddCookie(&http.Cookie{Name: config.ACCESS_TOKEN_NAME, Value: "cookie"})
+	request.Header.Set(config.AUTHORZIATION_NAME, "Bearer header")
+
+	return request
+}
+
+func TestReadAccessTokenFollowsTokenSources(t *testing.T) {
+	app := newTestApplication(t)
+	app.Config.JWT.QueryParam = "access_token"
+
+	cases := []struct {
+		sources []string
+		token   string
+	}{
+		{[]string{config.TOKEN_SOURCE_COOKIE, config.TOKEN_SOURCE_HEADER, config.TOKEN_SOURCE_QUERY}, "cookie"},
+		{[]string{config.TOKEN_SOURCE_HEADER, config.TOKEN_SOURCE_COOKIE, config.TOKEN_SOURCE_QUERY}, "header"},
+		{[]string{config.TOKEN_SOURCE_QUERY, config.TOKEN_SOURCE_HEADER, config.TOKEN_SOURCE_COOKIE}, "query"},
+		{[]string{config.TOKEN_SOURCE_HEADER}, "header"},
+	}
+	for _, c := range cases {
+		app.Config.JWT.TokenSources = c.sources
+		ctx, _ := newTestContext(app, tokenRequest(app))
+		token, err := app.readAccessToken(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, c.token, token, c.sources)
+	}
+
+	// sources that request does not carry are skipped
+	app.Config.JWT.TokenSources = []string{config.TOKEN_SOURCE_COOKIE, config.TOKEN_SOURCE_QUERY}
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/users?access_token=query", nil)
+	ctx, _ := newTestContext(app, request)
+	token, err := app.readAccessToken(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "query", token)
+
+	// sources that are not configured are not read
+	app.Config.JWT.TokenSources = []string{config.TOKEN_SOURCE_COOKIE}
+	ctx, _ = newTestContext(app, request)
+	token, err = app.readAccessToken(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "", token)
+}
+
+func TestAuthenticateChallenges(t *testing.T) {
+	app := newTestApplication(t)
+
+	// request without token gets challenge without error
+	ctx, recorder := newTestContext(app, httptest.NewRequest(http.MethodGet, "/api/v1/users", nil))
+	_, err := app.authenticate(ctx)
+	assert.IsType(t, ErrUnauthorized{}, err)
+	assert.Equal(t, `Bearer realm="`+config.NAME+`"`, recorder.Header().Get("WWW-Authenticate"))
+
+	// invalid_request is 400 by RFC 6750 section 3.1
+	for _, header := range []string{"Basic YWJjOmRlZg==", "Bearer "} {
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+		request.Header.Set(config.AUTHORZIATION_NAME, header)
+		ctx, recorder = newTestContext(app, request)
+		_, err = app.authenticate(ctx)
+		assert.Equal(t, http.StatusBadRequest, err.(ErrHTTP).Code(), header)
+		assert.Equal(t, BEARER_INVALID_REQUEST, ErrorCode(err.(ErrHTTP)), header)
+		assert.Contains(t, recorder.Header().Get("WWW-Authenticate"), `error="invalid_request"`, header)
+	}
+
+	// invalid_token is 401
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+	request.Header.Set(config.AUTHORZIATION_NAME, "Bearer invalid")
+	ctx, recorder = newTestContext(app, request)
+	_, err = app.authenticate(ctx)
+	assert.IsType(t, ErrUnauthorized{}, err)
+	assert.Contains(t, recorder.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+}
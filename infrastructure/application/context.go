@@ -1,10 +1,17 @@
 package application
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"io"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 
 	"microtecture/domain/models"
 )
@@ -14,20 +21,23 @@ type Context struct {
 	Request       *http.Request
 	Response      http.ResponseWriter
 	RemoteAddress string
+	RequestID     string
 	User          *models.User
+	Principal     *Principal
+	Logger        logrus.FieldLogger
 }
 
 // NewContext creates and returns Context
 func NewContext() *Context {
-	return &Context{}
+	return &Context{Logger: logrus.StandardLogger()}
 }
 
 // WithLogger add logger to context instance
-//func (self *Context) WithLogger(logger logrus.FieldLogger) *Context {
-//	ret := self
-//	ret.Logger = logger
-//	return ret
-//}
+func (self *Context) WithLogger(logger logrus.FieldLogger) *Context {
+	ret := self
+	ret.Logger = logger
+	return ret
+}
 
 // WithUser add user to context instance
 func (self *Context) WithUser(user *models.User) *Context {
@@ -36,6 +46,32 @@ func (self *Context) WithUser(user *models.User) *Context {
 	return ret
 }
 
+// WithPrincipal add authenticated principal to context instance
+func (self *Context) WithPrincipal(principal *Principal) *Context {
+	ret := self
+	ret.Principal = principal
+	return ret
+}
+
+// LoadUser returns user of context and loads it from database by principal if not loaded
+func (self *Context) LoadUser() (*models.User, error) {
+	if self.User != nil {
+		return self.User, nil
+	}
+
+	if self.Principal == nil || self.Principal.loader == nil {
+		return nil, NewErrUnauthorized()
+	}
+
+	user, err := self.Principal.loader()
+	if err != nil {
+		return nil, err
+	}
+	self.User = user
+
+	return user, nil
+}
+
 // WithRequest add http request instance to context instance
 func (self *Context) WithRequest(request *http.Request) *Context {
 	ret := self
@@ -50,34 +86,151 @@ func (self *Context) WithResponseWriter(responseWriter http.ResponseWriter) *Con
 	return ret
 }
 
-// DecodeMoel decodes model from context request to an interface domain model
+// StatusCode returns status code written to context response, 200 if not written yet
+func (self *Context) StatusCode() int {
+	if recorder, ok := self.Response.(*statusCodeRecorder); ok && recorder.StatusCode != 0 {
+		return recorder.StatusCode
+	}
+
+	return http.StatusOK
+}
+
+// DecodeModel decodes model from context request to an interface domain model
+// and validates it by its validate tags, unknown fields and trailing data are rejected
 func (self *Context) DecodeModel(v interface{}) error {
-	if err := json.NewDecoder(self.Request.Body).Decode(v); err != nil {
-		return NewErrValidation(err.Error())
+	decoder := json.NewDecoder(self.Request.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		if isBodyTooLarge(err) {
+			return errBodyTooLarge()
+		}
+		return NewErrValidation(err.Error()).WithCode(CODE_MALFORMED_BODY)
 	}
-	return nil
+
+	if _, err := decoder.Token(); err != io.EOF {
+		if isBodyTooLarge(err) {
+			return errBodyTooLarge()
+		}
+		return NewErrValidation("request body must have only one json value").WithCode(CODE_MALFORMED_BODY)
+	}
+
+	return Validate(v)
 }
 
-// Finish writes data with json format and header to http response
+// Finish writes status and data in format that request accepts (json, msgpack or protobuf)
+// to http response, nil data writes only status
 func (self *Context) Finish(status int, v interface{}) error {
-	if v != nil {
-		if err := self.json(v); err != nil {
-			return err
+	if v == nil {
+		self.Response.WriteHeader(status)
+		return nil
+	}
+
+	contentType, body, err := self.negotiate(v)
+	if err != nil {
+		return err
+	}
+
+	return self.write(status, contentType, body)
+}
+
+// FinishWithETag is Finish that tags response with hash of its body
+// and writes 304 Not Modified if request has same tag in If-None-Match
+func (self *Context) FinishWithETag(status int, v interface{}) error {
+	contentType, body, err := self.negotiate(v)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(append([]byte(contentType), body...))
+	if self.NotModified(`"` + hex.EncodeToString(sum[:16]) + `"`) {
+		return nil
+	}
+
+	return self.write(status, contentType, body)
+}
+
+// NotModified sets ETag header of response and writes 304 Not Modified
+// if If-None-Match header of request matches etag, etag must be quoted
+func (self *Context) NotModified(etag string) bool {
+	self.Response.Header().Set("ETag", etag)
+
+	for _, tag := range strings.Split(self.Request.Header.Get("If-None-Match"), ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == strings.TrimPrefix(etag, "W/") {
+			self.Response.WriteHeader(http.StatusNotModified)
+			return true
 		}
 	}
 
+	return false
+}
+
+func (self *Context) write(status int, contentType string, body []byte) error {
+	self.Response.Header().Set("Content-Type", contentType)
 	self.Response.WriteHeader(status)
+	_, err := self.Response.Write(body)
+	return HandleError(err)
+}
+
+// NoContent writes 204 No Content to http response
+func (self *Context) NoContent() error {
+	self.Response.WriteHeader(http.StatusNoContent)
 	return nil
 }
 
-func (self *Context) json(v interface{}) error {
-	err := json.NewEncoder(self.Response).Encode(v)
-	return HandleError(err)
+// Redirect redirects request to url with a 3xx status
+func (self *Context) Redirect(status int, url string) error {
+	http.Redirect(self.Response, self.Request, url, status)
+	return nil
+}
+
+// File writes file content to http response, handles Range and If-Modified-Since headers
+func (self *Context) File(path string) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) || (err == nil && info.IsDir()) {
+		return NewErrNotFound("file")
+	}
+	if err != nil {
+		return errors.New(err.Error())
+	}
+
+	http.ServeFile(self.Response, self.Request, path)
+	return nil
+}
+
+// Stream copies reader to http response with status and content type, flushes while copying
+func (self *Context) Stream(status int, contentType string, reader io.Reader) error {
+	self.Response.Header().Set("Content-Type", contentType)
+	self.Response.WriteHeader(status)
+
+	flusher, _ := self.Response.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := reader.Read(buf)
+		if n > 0 {
+			if _, err := self.Response.Write(buf[:n]); err != nil {
+				return errors.New(err.Error())
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.New(err.Error())
+		}
+	}
 }
 
 // ReadCookie reads cookie from context request
 func (self *Context) ReadCookie(cookieName string) (cookieValue string, err error) {
 	cookie, err := self.Request.Cookie(cookieName)
+	if err == http.ErrNoCookie {
+		return "", err
+	}
 	if err != nil {
 		return "", errors.New(err.Error())
 	}
@@ -85,14 +238,37 @@ func (self *Context) ReadCookie(cookieName string) (cookieValue string, err erro
 	return cookie.Value, nil
 }
 
+// CookieOptions is attributes of cookie that is set to context response
+type CookieOptions struct {
+	Path     string
+	Domain   string
+	Expires  time.Time
+	MaxAge   int // second count
+	Secure   bool
+	HTTPOnly bool
+	SameSite http.SameSite
+}
+
 // SetCookie sets cookie to context response
-// maxAge is second count
-func (self *Context) SetCookie(name, value string, maxAge uint) {
+func (self *Context) SetCookie(name, value string, options CookieOptions) {
 	cookie := &http.Cookie{
-		Name:   name,
-		Value:  value,
-		MaxAge: int(maxAge),
+		Name:     name,
+		Value:    value,
+		Path:     options.Path,
+		Domain:   options.Domain,
+		Expires:  options.Expires,
+		MaxAge:   options.MaxAge,
+		Secure:   options.Secure,
+		HttpOnly: options.HTTPOnly,
+		SameSite: options.SameSite,
 	}
 
 	http.SetCookie(self.Response, cookie)
 }
+
+// DeleteCookie expires cookie in client, path and domain of options must be same as set cookie
+func (self *Context) DeleteCookie(name string, options CookieOptions) {
+	options.MaxAge = -1
+	options.Expires = time.Unix(0, 0)
+	self.SetCookie(name, "", options)
+}
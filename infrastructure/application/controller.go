@@ -1,6 +1,7 @@
 package application
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"runtime/debug"
@@ -9,6 +10,12 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	maxBodySize = 100 * 1024 * 1024
+
+	REQUEST_ID_HEADER = "X-Request-ID"
+)
+
 type statusCodeRecorder struct {
 	http.ResponseWriter
 	http.Hijacker
@@ -20,12 +27,19 @@ func (r *statusCodeRecorder) WriteHeader(statusCode int) {
 	r.ResponseWriter.WriteHeader(statusCode)
 }
 
+func (r *statusCodeRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
 type Controller struct {
 	Application application
 }
 
 type RestController struct {
 	Controller
+	middlewares []Middleware
 }
 
 // NewController creates and returns controller
@@ -34,74 +48,170 @@ func NewController(a application) (Controller, error) {
 	return controller, nil
 }
 
-// NewRestController creates and returns restController
+// NewRestController creates and returns restController with default middlewares
+// that set request id and remote address, trace and measure requests, set request logger,
+// log requests, write errors, recover panics and limit request body
 func NewRestController(controller Controller) RestController {
-	return RestController{controller}
+	self := RestController{Controller: controller}
+	self.middlewares = []Middleware{
+		self.RequestID,
+		self.RemoteAddr,
+		self.Trace,
+		self.Measure,
+		self.RequestLogger,
+		self.LogRequest,
+		self.WriteError,
+		self.RecoverPanic,
+		LimitBody(maxBodySize),
+	}
+
+	return self
 }
 
-type action func(*Context) error
+// Action is handler of a request
+type Action func(*Context) error
 
-func (self RestController) Handle(f action) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		beginTime := time.Now()
-		r.Body = http.MaxBytesReader(w, r.Body, 100*1024*1024)
-		defer r.Body.Close()
+// Middleware wraps an action to run something before or after it
+type Middleware func(Action) Action
+
+// Use returns copy of controller that runs middlewares after its own ones,
+// use it to make a group of routes with common middlewares
+func (self RestController) Use(middlewares ...Middleware) RestController {
+	chain := make([]Middleware, 0, len(self.middlewares)+len(middlewares))
+	chain = append(chain, self.middlewares...)
+	chain = append(chain, middlewares...)
+	self.middlewares = chain
 
-		ctx := NewContext().WithRequest(r).WithResponseWriter(w)
+	return self
+}
 
+// Handle returns http handler of action wrapped in middlewares of controller,
+// then in route middlewares
+func (self RestController) Handle(f Action, middlewares ...Middleware) http.Handler {
+	action := f
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		action = middlewares[i](action)
+	}
+	for i := len(self.middlewares) - 1; i >= 0; i-- {
+		action = self.middlewares[i](action)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		hijacker, _ := w.(http.Hijacker)
-		w = &statusCodeRecorder{
+		recorder := &statusCodeRecorder{
 			ResponseWriter: w,
 			Hijacker:       hijacker,
 		}
 
-		defer func() {
-			statusCode := w.(*statusCodeRecorder).StatusCode
-			if statusCode == 0 {
-				statusCode = 200
-			}
-			duration := time.Since(beginTime)
+		ctx := NewContext().WithRequest(r).WithResponseWriter(recorder)
+
+		if err := action(ctx); err != nil {
+			self.writeError(ctx, err)
+		}
+	})
+}
+
+// RequestLogger sets logger of context with method, path, request id, remote address
+// and trace id of request, Authorize adds user id to it
+func (self RestController) RequestLogger(f Action) Action {
+	return func(ctx *Context) error {
+		fields := logrus.Fields{
+			"method":     ctx.Request.Method,
+			"path":       ctx.Request.URL.Path,
+			"request_id": ctx.RequestID,
+			"remote":     ctx.RemoteAddress,
+		}
+		if spanContext := ctx.Span().SpanContext(); spanContext.IsValid() {
+			fields["trace_id"] = spanContext.TraceID().String()
+		}
+		ctx.WithLogger(self.Application.Logger.WithFields(fields))
+
+		return f(ctx)
+	}
+}
 
-			logger := self.Application.Logger.WithFields(logrus.Fields{
-				"duration":    duration,
-				"status_code": statusCode,
-				"remote":      ctx.RemoteAddress,
+// LogRequest logs uri, duration and status code of request with logger of context
+func (self RestController) LogRequest(f Action) Action {
+	return func(ctx *Context) error {
+		beginTime := time.Now()
+
+		defer func() {
+			logger := ctx.Logger.WithFields(logrus.Fields{
+				"duration":    time.Since(beginTime),
+				"status_code": ctx.StatusCode(),
 			})
-			logger.Info(r.Method + " " + r.URL.RequestURI())
+			logger.Info(ctx.Request.Method + " " + ctx.Request.URL.RequestURI())
 		}()
 
+		return f(ctx)
+	}
+}
+
+// WriteError writes error of action to response as problem json,
+// ErrHTTP with its code and others as internal error
+func (self RestController) WriteError(f Action) Action {
+	return func(ctx *Context) error {
+		if err := f(ctx); err != nil {
+			self.writeError(ctx, err)
+		}
+
+		return nil
+	}
+}
+
+func (self RestController) writeError(ctx *Context, err error) {
+	e, ok := mapError(err).(ErrHTTP)
+	if !ok {
+		ctx.Logger.Error(fmt.Sprintf("%+v\n", err))
+		ctx.traceError(err)
+		e = NewErrCustom(
+			http.StatusInternalServerError,
+			http.StatusText(http.StatusInternalServerError),
+		).WithCode(CODE_INTERNAL)
+	}
+
+	problem := NewProblem(e)
+	problem.Instance = ctx.Request.URL.Path
+	problem.RequestID = ctx.RequestID
+
+	ctx.Response.Header().Set("Content-Type", PROBLEM_CONTENT_TYPE)
+	ctx.Response.Header().Set("X-Content-Type-Options", "nosniff")
+	ctx.Response.WriteHeader(problem.Status)
+	if err := json.NewEncoder(ctx.Response).Encode(problem); err != nil {
+		ctx.Logger.Error(fmt.Sprintf("%+v\n", err))
+	}
+}
+
+// RecoverPanic recovers panic of action and returns it as internal error
+func (self RestController) RecoverPanic(f Action) Action {
+	return func(ctx *Context) (err error) {
 		defer func() {
 			if r := recover(); r != nil {
-				self.Application.Logger.Error(fmt.Errorf("%v: %s", r, debug.Stack()))
-				http.Error(
-					w,
-					http.StatusText(http.StatusInternalServerError),
-					http.StatusInternalServerError,
-				)
+				err = fmt.Errorf("%v: %s", r, debug.Stack())
 			}
 		}()
 
-		w.Header().Set("Content-Type", "application/json")
+		return f(ctx)
+	}
+}
 
-		httperror := func(w http.ResponseWriter, code int, message string) {
-			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-			w.Header().Set("X-Content-Type-Options", "nosniff")
-			w.WriteHeader(code)
-			fmt.Fprint(w, message)
-		}
+// isBodyTooLarge reports whether err is of reading a body that LimitBody cuts
+func isBodyTooLarge(err error) bool {
+	return err != nil && err.Error() == "http: request body too large"
+}
 
-		if err := f(ctx); err != nil {
-			switch e := err.(type) {
-			case ErrHTTP:
-				httperror(w, e.Code(), e.Error())
-			default:
-				self.Application.Logger.Error(fmt.Sprintf("%+v\n", err))
-				httperror(
-					w,
-					http.StatusInternalServerError,
-					http.StatusText(http.StatusInternalServerError),
-				)
-			}
+func errBodyTooLarge() ErrCustom {
+	return NewErrCustom(http.StatusRequestEntityTooLarge, "request body is too large").WithCode(CODE_BODY_TOO_LARGE)
+}
+
+// LimitBody limits request body to size bytes, larger bodies are rejected with 413
+func LimitBody(size int64) Middleware {
+	return func(f Action) Action {
+		return func(ctx *Context) error {
+			ctx.Request.Body = http.MaxBytesReader(ctx.Response, ctx.Request.Body, size)
+			defer ctx.Request.Body.Close()
+
+			return f(ctx)
 		}
-	})
+	}
 }
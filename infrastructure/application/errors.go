@@ -2,8 +2,28 @@ package application
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/pkg/errors"
+
+	repository "microtecture/usecase/repositories"
+)
+
+const (
+	CODE_INTERNAL       = "internal_error"
+	CODE_CONFLICT       = "conflict"
+	CODE_NOT_FOUND      = "not_found"
+	CODE_VALIDATION     = "validation_failed"
+	CODE_MALFORMED_BODY = "malformed_body"
+	CODE_BODY_TOO_LARGE = "body_too_large"
+	CODE_REQUIRED       = "required"
+	CODE_UNAUTHORIZED   = "unauthorized"
+	CODE_FORBIDDEN      = "forbidden"
+
+	CODE_DUPLICATE_MOBILE_NUMBER = "duplicate_mobile_number"
+	CODE_USER_NOT_FOUND          = "user_not_found"
+
+	PROBLEM_CONTENT_TYPE = "application/problem+json"
 )
 
 func HandleError(err error) error {
@@ -19,15 +39,144 @@ type ErrHTTP interface {
 	Error() string
 }
 
+// ErrCoded is ErrHTTP with a machine readable code, like duplicate_mobile_number,
+// every Err type of this package is ErrCoded
+type ErrCoded interface {
+	ErrHTTP
+	ErrorCode() string
+}
+
+// ErrorCode returns code of error, or code of its status if it is not ErrCoded
+func ErrorCode(err ErrHTTP) string {
+	if e, ok := err.(ErrCoded); ok && e.ErrorCode() != "" {
+		return e.ErrorCode()
+	}
+
+	return statusErrorCode(err.Code())
+}
+
+// statusErrorCode returns code of errors that have only status, like service_unavailable for 503
+func statusErrorCode(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CODE_VALIDATION
+	case http.StatusUnauthorized:
+		return CODE_UNAUTHORIZED
+	case http.StatusForbidden:
+		return CODE_FORBIDDEN
+	case http.StatusNotFound:
+		return CODE_NOT_FOUND
+	case http.StatusConflict:
+		return CODE_CONFLICT
+	case http.StatusInternalServerError:
+		return CODE_INTERNAL
+	}
+
+	text := http.StatusText(status)
+	if text == "" {
+		return CODE_INTERNAL
+	}
+
+	return strings.ReplaceAll(strings.ToLower(strings.ReplaceAll(text, "-", " ")), " ", "_")
+}
+
+// mapError maps errors of repositories that clients tell apart to ErrHTTP with their code,
+// so handlers may return them as they are
+func mapError(err error) error {
+	switch err {
+	case repository.ErrDuplicateMobileNumber:
+		return NewErrConflict(err.Error()).WithCode(CODE_DUPLICATE_MOBILE_NUMBER)
+	case repository.ErrUserNotFound:
+		return NewErrNotFound("user").WithCode(CODE_USER_NOT_FOUND)
+	}
+
+	return err
+}
+
+// FieldError is error of a field of request
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// Problem is error response body of RFC 7807
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"requestId,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// NewProblem creates and returns problem of error
+func NewProblem(err ErrHTTP) Problem {
+	problem := Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(err.Code()),
+		Status: err.Code(),
+		Detail: err.Error(),
+		Code:   ErrorCode(err),
+	}
+
+	if e, ok := err.(ErrValidation); ok {
+		problem.Errors = e.Fields()
+	}
+
+	return problem
+}
+
+// NewErrFromProblem maps problem json of another service back to ErrHTTP by its status,
+// errors without a matching type are ErrCustom
+func NewErrFromProblem(problem Problem) ErrHTTP {
+	message := problem.Detail
+	if message == "" {
+		message = problem.Title
+	}
+	if message == "" {
+		message = http.StatusText(problem.Status)
+	}
+
+	switch problem.Status {
+	case http.StatusBadRequest:
+		return ErrValidation{errorCode: problem.Code, message: message, fields: problem.Errors}
+	case http.StatusUnauthorized:
+		return NewErrUnauthorized()
+	case http.StatusForbidden:
+		return NewErrForbidden()
+	case http.StatusNotFound:
+		return ErrNotFound{errorCode: problem.Code, message: message}
+	case http.StatusConflict:
+		return ErrConflict{errorCode: problem.Code, message: message}
+	}
+
+	status := problem.Status
+	if status < http.StatusBadRequest {
+		status = http.StatusInternalServerError
+	}
+
+	return NewErrCustom(status, message).WithCode(problem.Code)
+}
+
 type ErrCustom struct {
-	code    int
-	message string
+	code      int
+	errorCode string
+	message   string
 }
 
 func NewErrCustom(code int, msg string) ErrCustom {
 	return ErrCustom{code: code, message: msg}
 }
 
+// WithCode returns error with a more specific code than code of its status
+func (self ErrCustom) WithCode(errorCode string) ErrCustom {
+	self.errorCode = errorCode
+	return self
+}
+
 func (self ErrCustom) Error() string {
 	return self.message
 }
@@ -36,14 +185,28 @@ func (self ErrCustom) Code() int {
 	return self.code
 }
 
+func (self ErrCustom) ErrorCode() string {
+	if self.errorCode == "" {
+		return statusErrorCode(self.code)
+	}
+	return self.errorCode
+}
+
 type ErrConflict struct {
-	message string
+	errorCode string
+	message   string
 }
 
 func NewErrConflict(msg string) ErrConflict {
 	return ErrConflict{message: msg}
 }
 
+// WithCode returns error with a more specific code than conflict
+func (self ErrConflict) WithCode(errorCode string) ErrConflict {
+	self.errorCode = errorCode
+	return self
+}
+
 func (self ErrConflict) Error() string {
 	return self.message
 }
@@ -52,14 +215,28 @@ func (self ErrConflict) Code() int {
 	return http.StatusConflict
 }
 
+func (self ErrConflict) ErrorCode() string {
+	if self.errorCode == "" {
+		return CODE_CONFLICT
+	}
+	return self.errorCode
+}
+
 type ErrNotFound struct {
-	message string
+	errorCode string
+	message   string
 }
 
 func NewErrNotFound(name string) ErrNotFound {
 	return ErrNotFound{message: name + " not found"}
 }
 
+// WithCode returns error with a more specific code than not_found
+func (self ErrNotFound) WithCode(errorCode string) ErrNotFound {
+	self.errorCode = errorCode
+	return self
+}
+
 func (self ErrNotFound) Error() string {
 	return self.message
 }
@@ -68,12 +245,27 @@ func (self ErrNotFound) Code() int {
 	return http.StatusNotFound
 }
 
+func (self ErrNotFound) ErrorCode() string {
+	if self.errorCode == "" {
+		return CODE_NOT_FOUND
+	}
+	return self.errorCode
+}
+
 type ErrValidation struct {
-	message string
+	errorCode string
+	message   string
+	fields    []FieldError
+}
+
+func NewErrValidation(msg string, fields ...FieldError) ErrValidation {
+	return ErrValidation{message: msg, fields: fields}
 }
 
-func NewErrValidation(msg string) ErrValidation {
-	return ErrValidation{message: msg}
+// WithCode returns error with a more specific code than validation_failed
+func (self ErrValidation) WithCode(errorCode string) ErrValidation {
+	self.errorCode = errorCode
+	return self
 }
 
 func (self ErrValidation) Error() string {
@@ -84,6 +276,18 @@ func (self ErrValidation) Code() int {
 	return http.StatusBadRequest
 }
 
+func (self ErrValidation) ErrorCode() string {
+	if self.errorCode == "" {
+		return CODE_VALIDATION
+	}
+	return self.errorCode
+}
+
+// Fields returns errors of invalid fields
+func (self ErrValidation) Fields() []FieldError {
+	return self.fields
+}
+
 type ErrUnauthorized struct{}
 
 func NewErrUnauthorized() ErrUnauthorized { return ErrUnauthorized{} }
@@ -96,6 +300,10 @@ func (self ErrUnauthorized) Code() int {
 	return http.StatusUnauthorized
 }
 
+func (self ErrUnauthorized) ErrorCode() string {
+	return CODE_UNAUTHORIZED
+}
+
 type ErrForbidden struct{}
 
 func NewErrForbidden() ErrForbidden { return ErrForbidden{} }
@@ -107,3 +315,7 @@ func (self ErrForbidden) Error() string {
 func (self ErrForbidden) Code() int {
 	return http.StatusForbidden
 }
+
+func (self ErrForbidden) ErrorCode() string {
+	return CODE_FORBIDDEN
+}
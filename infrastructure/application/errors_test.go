@@ -0,0 +1,85 @@
+package application
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alecthomas/assert"
+
+	repository "microtecture/usecase/repositories"
+)
+
+// legacyErr is ErrHTTP of a caller that does not implement ErrorCode
+type legacyErr struct{}
+
+func (self legacyErr) Code() int     { return http.StatusServiceUnavailable }
+func (self legacyErr) Error() string { return "down" }
+
+func serveError(t *testing.T, err error) (*httptest.ResponseRecorder, Problem) {
+	controller := NewRestController(Controller{Application: newTestApplication(t)})
+	recorder := httptest.NewRecorder()
+	controller.Handle(func(ctx *Context) error { return err }).
+		ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/v1/users", nil))
+
+	problem := Problem{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &problem))
+
+	return recorder, problem
+}
+
+func TestWriteErrorMapsRepositoryErrors(t *testing.T) {
+	recorder, problem := serveError(t, repository.ErrDuplicateMobileNumber)
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+	assert.Equal(t, PROBLEM_CONTENT_TYPE, recorder.Header().Get("Content-Type"))
+	assert.Equal(t, CODE_DUPLICATE_MOBILE_NUMBER, problem.Code)
+
+	recorder, problem = serveError(t, repository.ErrUserNotFound)
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Equal(t, CODE_USER_NOT_FOUND, problem.Code)
+}
+
+func TestWriteErrorCodes(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{NewErrValidation("bad", FieldError{Field: "mobileNumber", Code: CODE_REQUIRED}), 400, CODE_VALIDATION},
+		{NewErrConflict("taken").WithCode("taken"), 409, "taken"},
+		{NewErrCustom(http.StatusTooManyRequests, "slow down"), 429, "too_many_requests"},
+		{NewErrCustom(http.StatusServiceUnavailable, "down").WithCode("maintenance"), 503, "maintenance"},
+		{legacyErr{}, 503, "service_unavailable"},
+		{NewErrUnauthorized(), 401, CODE_UNAUTHORIZED},
+	}
+	for _, c := range cases {
+		recorder, problem := serveError(t, c.err)
+		assert.Equal(t, c.status, recorder.Code)
+		assert.Equal(t, c.status, problem.Status)
+		assert.Equal(t, c.code, problem.Code)
+	}
+
+	_, problem := serveError(t, NewErrValidation("bad", FieldError{Field: "mobileNumber", Code: CODE_REQUIRED}))
+	assert.Equal(t, []FieldError{{Field: "mobileNumber", Code: CODE_REQUIRED}}, problem.Errors)
+}
+
+func TestNewErrFromProblemCodes(t *testing.T) {
+	cases := []struct {
+		problem Problem
+		status  int
+		code    string
+	}{
+		{Problem{Status: http.StatusTooManyRequests}, 429, "too_many_requests"},
+		{Problem{Status: http.StatusServiceUnavailable, Code: "circuit_open"}, 503, "circuit_open"},
+		{Problem{Status: http.StatusBadGateway}, 502, "bad_gateway"},
+		{Problem{Status: http.StatusInternalServerError}, 500, CODE_INTERNAL},
+		{Problem{Status: http.StatusNotFound, Code: CODE_USER_NOT_FOUND}, 404, CODE_USER_NOT_FOUND},
+		{Problem{Status: http.StatusFound}, 500, CODE_INTERNAL},
+	}
+	for _, c := range cases {
+		e := NewErrFromProblem(c.problem)
+		assert.Equal(t, c.status, e.Code())
+		assert.Equal(t, c.code, ErrorCode(e))
+	}
+}
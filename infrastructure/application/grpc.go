@@ -0,0 +1,419 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"microtecture/infrastructure/config"
+	"microtecture/infrastructure/metrics"
+)
+
+const (
+	GRPC_REQUEST_ID_KEY    = "x-request-id"
+	GRPC_AUTHORIZATION_KEY = "authorization"
+)
+
+type loggerKey struct{}
+
+type principalKey struct{}
+
+// LoggerFromContext returns logger of grpc call with its method, request id, remote address
+// and user id, standard logger if context has not
+func LoggerFromContext(ctx context.Context) logrus.FieldLogger {
+	if logger, ok := ctx.Value(loggerKey{}).(logrus.FieldLogger); ok {
+		return logger
+	}
+
+	return logrus.StandardLogger()
+}
+
+// PrincipalFromContext returns authenticated principal of grpc call or http request
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok
+}
+
+type GrpcController struct {
+	Controller
+	unary  []grpc.UnaryServerInterceptor
+	stream []grpc.StreamServerInterceptor
+}
+
+// NewGrpcController creates and returns grpc controller with default interceptors
+// that set request id and logger, log calls, map errors to grpc status and recover panics
+func NewGrpcController(controller Controller) GrpcController {
+	self := GrpcController{Controller: controller}
+	self.unary = []grpc.UnaryServerInterceptor{self.UnaryLog, self.UnaryRecover}
+	self.stream = []grpc.StreamServerInterceptor{self.StreamLog, self.StreamRecover}
+
+	return self
+}
+
+// Use returns copy of controller that runs interceptors after its own ones
+func (self GrpcController) Use(
+	unary []grpc.UnaryServerInterceptor, stream []grpc.StreamServerInterceptor,
+) GrpcController {
+	self.unary = append(append([]grpc.UnaryServerInterceptor{}, self.unary...), unary...)
+	self.stream = append(append([]grpc.StreamServerInterceptor{}, self.stream...), stream...)
+
+	return self
+}
+
+// NewServer creates grpc server with tls, max message size and interceptors of controller
+func (self GrpcController) NewServer(options ...grpc.ServerOption) (*grpc.Server, error) {
+	conf := self.Application.Config.GRPC
+
+	serverOptions := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(conf.MaxMessageSize),
+		grpc.MaxSendMsgSize(conf.MaxMessageSize),
+		grpc.ChainUnaryInterceptor(self.unary...),
+		grpc.ChainStreamInterceptor(self.stream...),
+	}
+
+	if conf.TLS() {
+		creds, err := credentials.NewServerTLSFromFile(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, errors.New(err.Error())
+		}
+		serverOptions = append(serverOptions, grpc.Creds(creds))
+	}
+
+	return grpc.NewServer(append(serverOptions, options...)...), nil
+}
+
+// callContext stores request id of metadata, or a generated one, and logger of call on context
+// and echoes request id in header of call
+func (self GrpcController) callContext(ctx context.Context, method string) (context.Context, logrus.FieldLogger) {
+	id := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(GRPC_REQUEST_ID_KEY); len(values) > 0 {
+			id = values[0]
+		}
+	}
+	if len(id) > MAX_REQUEST_ID_LENGTH || !requestIDPattern.MatchString(id) {
+		id = uuid.New().String()
+	}
+
+	remote := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		remote = p.Addr.String()
+	}
+
+	logger := self.Application.Logger.WithFields(logrus.Fields{
+		"method":     method,
+		"request_id": id,
+		"remote":     remote,
+	})
+
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+	ctx = context.WithValue(ctx, loggerKey{}, logger)
+	grpc.SetHeader(ctx, metadata.Pairs(GRPC_REQUEST_ID_KEY, id))
+
+	return ctx, logger
+}
+
+// UnaryLog sets request id and logger of call, logs its duration and status code
+// and maps its error to grpc status
+func (self GrpcController) UnaryLog(
+	ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (interface{}, error) {
+	ctx, logger := self.callContext(ctx, info.FullMethod)
+	beginTime := time.Now()
+
+	resp, err := handler(ctx, req)
+	err = grpcError(logger, err)
+
+	logger.WithFields(logrus.Fields{
+		"duration":    time.Since(beginTime),
+		"status_code": status.Code(err).String(),
+	}).Info(info.FullMethod)
+
+	return resp, err
+}
+
+// StreamLog is UnaryLog of streams
+func (self GrpcController) StreamLog(
+	srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler,
+) error {
+	ctx, logger := self.callContext(ss.Context(), info.FullMethod)
+	beginTime := time.Now()
+
+	err := handler(srv, serverStream{ss, ctx})
+	err = grpcError(logger, err)
+
+	logger.WithFields(logrus.Fields{
+		"duration":    time.Since(beginTime),
+		"status_code": status.Code(err).String(),
+	}).Info(info.FullMethod)
+
+	return err
+}
+
+// UnaryRecover recovers panic of call and returns it as internal error
+func (self GrpcController) UnaryRecover(
+	ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+) (resp interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v: %s", r, debug.Stack())
+		}
+	}()
+
+	return handler(ctx, req)
+}
+
+// StreamRecover is UnaryRecover of streams
+func (self GrpcController) StreamRecover(
+	srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler,
+) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v: %s", r, debug.Stack())
+		}
+	}()
+
+	return handler(srv, ss)
+}
+
+// Authorize returns copy of controller whose calls must carry a valid access token as bearer of
+// authorization metadata, policies of a method like {"/users.v1.Users/Delete": {AnyRole("admin")}}
+// must permit its caller as in Authorize of rest controller. request of their context carries
+// context of call and full method as path.
+// public methods like /grpc.health.v1.Health/Check are not checked.
+func (self GrpcController) Authorize(policies map[string][]Policy, public ...string) GrpcController {
+	skip := make(map[string]bool)
+	for _, method := range public {
+		skip[method] = true
+	}
+
+	authorize := func(ctx context.Context, method string) (context.Context, error) {
+		if skip[method] {
+			return ctx, nil
+		}
+
+		principal, err := self.Application.authenticateGrpc(ctx)
+		if err != nil {
+			metrics.ObserveAuth(metrics.AUTH_UNAUTHORIZED)
+			return ctx, err
+		}
+
+		logger := LoggerFromContext(ctx).WithField("user_id", principal.Id)
+		ctx = context.WithValue(ctx, principalKey{}, principal)
+		ctx = context.WithValue(ctx, loggerKey{}, logger)
+
+		request := (&http.Request{Method: http.MethodPost, URL: &url.URL{Path: method}, Header: http.Header{}}).
+			WithContext(ctx)
+		policyCtx := NewContext().WithRequest(request).WithPrincipal(principal).WithLogger(logger)
+		if err := permit(policyCtx, policies[method]); err != nil {
+			return ctx, err
+		}
+
+		return ctx, nil
+	}
+
+	unary := func(
+		ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		ctx, err := authorize(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+
+	stream := func(
+		srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler,
+	) error {
+		ctx, err := authorize(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, serverStream{ss, ctx})
+	}
+
+	return self.Use(
+		[]grpc.UnaryServerInterceptor{unary},
+		[]grpc.StreamServerInterceptor{stream},
+	)
+}
+
+// authenticateGrpc verifies access token of authorization metadata, expired tokens are not refreshed
+func (self application) authenticateGrpc(ctx context.Context) (*Principal, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(GRPC_AUTHORIZATION_KEY)
+	if len(values) == 0 {
+		return nil, NewErrUnauthorized()
+	}
+
+	tokenString, ok := parseBearer(values[0])
+	if !ok {
+		return nil, NewErrUnauthorized()
+	}
+
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, self.keys.verificationKey)
+	if err != nil || !token.Valid {
+		return nil, NewErrUnauthorized()
+	}
+
+	principal := self.newPrincipal(ctx, claims)
+	principal.Token = tokenString
+
+	return principal, nil
+}
+
+// serverStream is server stream with context of its call
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (self serverStream) Context() context.Context {
+	return self.ctx
+}
+
+// grpcError maps ErrHTTP to grpc status with its error code as ErrorInfo detail
+// and its field errors as BadRequest detail, others are logged and returned as internal error
+func grpcError(logger logrus.FieldLogger, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	e, ok := mapError(err).(ErrHTTP)
+	if !ok {
+		logger.Error(fmt.Sprintf("%+v\n", err))
+		return status.Error(codes.Internal, http.StatusText(http.StatusInternalServerError))
+	}
+
+	st := status.New(grpcCode(e.Code()), e.Error())
+	info := &errdetails.ErrorInfo{Reason: ErrorCode(e), Domain: config.NAME}
+
+	if v, ok := e.(ErrValidation); ok && len(v.Fields()) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, field := range v.Fields() {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       field.Field,
+				Description: field.Code + ": " + field.Message,
+			})
+		}
+		if detailed, err := st.WithDetails(info, badRequest); err == nil {
+			return detailed.Err()
+		}
+		return st.Err()
+	}
+
+	if detailed, err := st.WithDetails(info); err == nil {
+		return detailed.Err()
+	}
+
+	return st.Err()
+}
+
+// NewErrFromStatus maps grpc status error of another service back to ErrHTTP
+// by its code and ErrorInfo and BadRequest details, it is reverse of grpcError
+func NewErrFromStatus(err error) ErrHTTP {
+	st := status.Convert(err)
+
+	problem := Problem{
+		Status: httpCode(st.Code()),
+		Detail: st.Message(),
+	}
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.ErrorInfo:
+			problem.Code = d.Reason
+		case *errdetails.BadRequest:
+			for _, violation := range d.FieldViolations {
+				field := FieldError{Field: violation.Field, Message: violation.Description}
+				if i := strings.Index(violation.Description, ": "); i > 0 {
+					field.Code, field.Message = violation.Description[:i], violation.Description[i+2:]
+				}
+				problem.Errors = append(problem.Errors, field)
+			}
+		}
+	}
+
+	return NewErrFromProblem(problem)
+}
+
+// grpcCode returns grpc status code of http status code
+func grpcCode(code int) codes.Code {
+	switch code {
+	case http.StatusBadRequest, http.StatusNotAcceptable, http.StatusUnsupportedMediaType:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusRequestEntityTooLarge, http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+
+	if code >= http.StatusInternalServerError {
+		return codes.Internal
+	}
+
+	return codes.FailedPrecondition
+}
+
+// httpCode returns http status code of grpc status code
+func httpCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	}
+
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,291 @@
+package application
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/alecthomas/assert"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"microtecture/infrastructure/config"
+)
+
+const (
+	grpcCheckMethod = "/grpc.health.v1.Health/Check"
+	grpcWatchMethod = "/grpc.health.v1.Health/Watch"
+)
+
+// testHealthServer answers by service of request: panic panics, invalid fails validation,
+// missing is not found and others are serving with principal of call
+type testHealthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+	principals chan *Principal
+}
+
+func (self testHealthServer) fail(ctx context.Context, service string) error {
+	switch service {
+	case "panic":
+		panic("test panic")
+	case "invalid":
+		return NewErrValidation("request has invalid fields", FieldError{
+			Field: "service", Code: CODE_REQUIRED, Message: "is required",
+		})
+	case "missing":
+		return NewErrNotFound("service")
+	}
+
+	principal, _ := PrincipalFromContext(ctx)
+	self.principals <- principal
+	return nil
+}
+
+func (self testHealthServer) Check(
+	ctx context.Context, req *grpc_health_v1.HealthCheckRequest,
+) (*grpc_health_v1.HealthCheckResponse, error) {
+	if err := self.fail(ctx, req.Service); err != nil {
+		return nil, err
+	}
+
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+}
+
+func (self testHealthServer) Watch(
+	req *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer,
+) error {
+	if err := self.fail(stream.Context(), req.Service); err != nil {
+		return err
+	}
+
+	return stream.Send(&grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING})
+}
+
+// newTestGrpcClient serves health server with interceptors of controller on a buffered listener
+// and returns its client and principals of calls that succeed
+func newTestGrpcClient(t *testing.T, controller GrpcController) (grpc_health_v1.HealthClient, chan *Principal) {
+	s, err := controller.NewServer()
+	assert.NoError(t, err)
+	principals := make(chan *Principal, 10)
+	grpc_health_v1.RegisterHealthServer(s, testHealthServer{principals: principals})
+
+	listener := bufconn.Listen(1 << 20)
+	go s.Serve(listener)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.DialContext(
+		context.Background(),
+		"bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, address string) (net.Conn, error) {
+			return listener.Dial()
+		}),
+		grpc.WithInsecure(),
+	)
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return grpc_health_v1.NewHealthClient(conn), principals
+}
+
+func newTestGrpcController(t *testing.T) (application, GrpcController) {
+	app := newTestApplication(t)
+	app.Config.GRPC.MaxMessageSize = 1 << 20
+
+	return app, NewGrpcController(Controller{Application: app})
+}
+
+// withToken returns context of call that carries token as bearer
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), GRPC_AUTHORIZATION_KEY, "Bearer "+token)
+}
+
+func check(ctx context.Context, client grpc_health_v1.HealthClient, service string) error {
+	_, err := client.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: service})
+	return err
+}
+
+func TestGrpcAuthorize(t *testing.T) {
+	app, controller := newTestGrpcController(t)
+	user := createTestUser(t, app)
+	adminToken, err := app.CreateJWT(user.Id, "", "", false, "admin")
+	assert.NoError(t, err)
+	userToken, err := app.CreateJWT(user.Id, "", "", false)
+	assert.NoError(t, err)
+
+	client, principals := newTestGrpcClient(t, controller.Authorize(map[string][]Policy{
+		grpcCheckMethod: {AnyRole("admin")},
+	}))
+
+	// missing and invalid tokens are unauthenticated
+	assert.Equal(t, codes.Unauthenticated, status.Code(check(context.Background(), client, "")))
+	assert.Equal(t, codes.Unauthenticated, status.Code(check(withToken("invalid"), client, "")))
+	basic := metadata.AppendToOutgoingContext(context.Background(), GRPC_AUTHORIZATION_KEY, "Basic YWJj")
+	assert.Equal(t, codes.Unauthenticated, status.Code(check(basic, client, "")))
+
+	// policies of method must permit caller
+	assert.Equal(t, codes.PermissionDenied, status.Code(check(withToken(userToken), client, "")))
+	assert.NoError(t, check(withToken(adminToken), client, ""))
+	principal := <-principals
+	assert.Equal(t, user.Id, principal.Id)
+	assert.Equal(t, adminToken, principal.Token)
+
+	// methods without policies need only a valid token
+	stream, err := client.Watch(withToken(userToken), &grpc_health_v1.HealthCheckRequest{})
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, user.Id, (<-principals).Id)
+
+	stream, err = client.Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestGrpcAuthorizeSkipsPublicMethods(t *testing.T) {
+	_, controller := newTestGrpcController(t)
+	client, principals := newTestGrpcClient(t, controller.Authorize(map[string][]Policy{
+		grpcCheckMethod: {AnyRole("admin")},
+	}, grpcCheckMethod))
+
+	assert.NoError(t, check(context.Background(), client, ""))
+	assert.Zero(t, <-principals)
+
+	// other methods are still checked
+	stream, err := client.Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestGrpcPolicyContext(t *testing.T) {
+	app, controller := newTestGrpcController(t)
+	user := createTestUser(t, app)
+	token, err := app.CreateJWT(user.Id, "", "", false)
+	assert.NoError(t, err)
+
+	methods := make(chan string, 1)
+	client, principals := newTestGrpcClient(t, controller.Authorize(map[string][]Policy{
+		grpcCheckMethod: {Owner(func(ctx *Context) (uuid.UUID, error) {
+			methods <- ctx.Request.URL.Path
+			md, _ := metadata.FromIncomingContext(ctx.Request.Context())
+			assert.Equal(t, []string{"Bearer " + token}, md.Get(GRPC_AUTHORIZATION_KEY))
+			return user.Id, nil
+		})},
+	}))
+
+	assert.NoError(t, check(withToken(token), client, ""))
+	assert.Equal(t, grpcCheckMethod, <-methods)
+	<-principals
+}
+
+func TestGrpcErrors(t *testing.T) {
+	_, controller := newTestGrpcController(t)
+	client, _ := newTestGrpcClient(t, controller)
+
+	// panics are internal errors that do not leak their message
+	err := check(context.Background(), client, "panic")
+	assert.Equal(t, codes.Internal, status.Code(err))
+	assert.Equal(t, http.StatusText(http.StatusInternalServerError), status.Convert(err).Message())
+
+	stream, err := client.Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "panic"})
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	// validation errors carry their code and fields as details
+	err = check(context.Background(), client, "invalid")
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	details := status.Convert(err).Details()
+	assert.Equal(t, 2, len(details))
+	assert.Equal(t, CODE_VALIDATION, details[0].(*errdetails.ErrorInfo).Reason)
+	assert.Equal(t, config.NAME, details[0].(*errdetails.ErrorInfo).Domain)
+	violations := details[1].(*errdetails.BadRequest).FieldViolations
+	assert.Equal(t, 1, len(violations))
+	assert.Equal(t, "service", violations[0].Field)
+	assert.Equal(t, "required: is required", violations[0].Description)
+
+	// status errors map back to errors of this package
+	validation, ok := NewErrFromStatus(err).(ErrValidation)
+	assert.True(t, ok)
+	assert.Equal(t, "request has invalid fields", validation.Error())
+	assert.Equal(t, []FieldError{{Field: "service", Code: CODE_REQUIRED, Message: "is required"}}, validation.Fields())
+
+	err = check(context.Background(), client, "missing")
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	notFound, ok := NewErrFromStatus(err).(ErrNotFound)
+	assert.True(t, ok)
+	assert.Equal(t, CODE_NOT_FOUND, notFound.ErrorCode())
+
+	// request id of call is echoed, or generated
+	var header metadata.MD
+	ctx := metadata.AppendToOutgoingContext(context.Background(), GRPC_REQUEST_ID_KEY, "request-1")
+	_, err = client.Check(ctx, &grpc_health_v1.HealthCheckRequest{Service: "missing"}, grpc.Header(&header))
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, []string{"request-1"}, header.Get(GRPC_REQUEST_ID_KEY))
+}
+
+func TestGrpcLog(t *testing.T) {
+	app, _ := newTestGrpcController(t)
+	logger, hook := test.NewNullLogger()
+	app.Logger = logger
+	client, _ := newTestGrpcClient(t, NewGrpcController(Controller{Application: app}))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), GRPC_REQUEST_ID_KEY, "request-1")
+	assert.NoError(t, check(ctx, client, ""))
+	entry := hook.LastEntry()
+	assert.Equal(t, logrus.InfoLevel, entry.Level)
+	assert.Equal(t, grpcCheckMethod, entry.Message)
+	assert.Equal(t, grpcCheckMethod, entry.Data["method"])
+	assert.Equal(t, "request-1", entry.Data["request_id"])
+	assert.Equal(t, codes.OK.String(), entry.Data["status_code"])
+
+	stream, err := client.Watch(ctx, &grpc_health_v1.HealthCheckRequest{Service: "missing"})
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	entry = hook.LastEntry()
+	assert.Equal(t, grpcWatchMethod, entry.Message)
+	assert.Equal(t, "request-1", entry.Data["request_id"])
+	assert.Equal(t, codes.NotFound.String(), entry.Data["status_code"])
+
+	// invalid request ids are replaced
+	ctx = metadata.AppendToOutgoingContext(context.Background(), GRPC_REQUEST_ID_KEY, "invalid id")
+	assert.NoError(t, check(ctx, client, ""))
+	_, err = uuid.Parse(hook.LastEntry().Data["request_id"].(string))
+	assert.NoError(t, err)
+}
+
+func TestGrpcCodes(t *testing.T) {
+	cases := map[int]codes.Code{
+		http.StatusBadRequest:          codes.InvalidArgument,
+		http.StatusUnauthorized:        codes.Unauthenticated,
+		http.StatusForbidden:           codes.PermissionDenied,
+		http.StatusNotFound:            codes.NotFound,
+		http.StatusConflict:            codes.AlreadyExists,
+		http.StatusTooManyRequests:     codes.ResourceExhausted,
+		http.StatusNotImplemented:      codes.Unimplemented,
+		http.StatusServiceUnavailable:  codes.Unavailable,
+		http.StatusGatewayTimeout:      codes.DeadlineExceeded,
+		http.StatusInternalServerError: codes.Internal,
+	}
+	for statusCode, code := range cases {
+		assert.Equal(t, code, grpcCode(statusCode), statusCode)
+		assert.Equal(t, statusCode, httpCode(code), statusCode)
+	}
+
+	assert.Equal(t, codes.ResourceExhausted, grpcCode(http.StatusRequestEntityTooLarge))
+	assert.Equal(t, codes.Internal, grpcCode(http.StatusBadGateway))
+	assert.Equal(t, codes.FailedPrecondition, grpcCode(http.StatusPreconditionFailed))
+	assert.Equal(t, http.StatusPreconditionFailed, httpCode(codes.FailedPrecondition))
+	assert.Equal(t, http.StatusInternalServerError, httpCode(codes.Unknown))
+}
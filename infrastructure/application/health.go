@@ -0,0 +1,187 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	HEALTH_UP       = "up"
+	HEALTH_DOWN     = "down"
+	HEALTH_DRAINING = "draining"
+)
+
+// Check is a readiness check of a dependency, it returns error if dependency is not usable
+type Check func(ctx context.Context) error
+
+// CheckResult is status, latency and error of a check
+type CheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Health is status of application and result of every check
+type Health struct {
+	Status    string                 `json:"status"`
+	Checks    map[string]CheckResult `json:"checks,omitempty"`
+	CheckedAt *time.Time             `json:"checkedAt,omitempty"`
+}
+
+// healthChecker runs readiness checks and caches their result for ttl,
+// concurrent probes share one run of checks
+type healthChecker struct {
+	mutex     *sync.Mutex
+	ttl       time.Duration
+	timeout   time.Duration
+	checks    map[string]Check
+	cached    Health
+	expiresAt time.Time
+	running   chan struct{}
+	draining  int32
+}
+
+func newHealthChecker(ttl, timeout time.Duration) *healthChecker {
+	return &healthChecker{
+		mutex:   &sync.Mutex{},
+		ttl:     ttl,
+		timeout: timeout,
+		checks:  make(map[string]Check),
+	}
+}
+
+// RegisterCheck adds readiness check by its name, check with same name is replaced
+func (self application) RegisterCheck(name string, check Check) {
+	self.health.mutex.Lock()
+	defer self.health.mutex.Unlock()
+
+	self.health.checks[name] = check
+	self.health.expiresAt = time.Time{}
+}
+
+// Drain makes application not ready, so load balancers stop sending requests before shutdown
+func (self application) Drain() {
+	atomic.StoreInt32(&self.health.draining, 1)
+}
+
+// Draining reports whether Drain is called
+func (self application) Draining() bool {
+	return atomic.LoadInt32(&self.health.draining) == 1
+}
+
+// Ready runs readiness checks concurrently, or returns their cached result,
+// application is up if every check passes and it is not draining.
+// checks run with their own timeout, not ctx, so a probe that gives up does not fail them
+// for probes after it, it only stops waiting and gets down.
+func (self application) Ready(ctx context.Context) Health {
+	if self.Draining() {
+		return Health{Status: HEALTH_DRAINING}
+	}
+
+	self.health.mutex.Lock()
+	if time.Now().Before(self.health.expiresAt) {
+		defer self.health.mutex.Unlock()
+		return self.health.cached
+	}
+	if self.health.running == nil {
+		self.health.running = make(chan struct{})
+		go self.health.run()
+	}
+	running := self.health.running
+	self.health.mutex.Unlock()
+
+	select {
+	case <-running:
+	case <-ctx.Done():
+		return Health{Status: HEALTH_DOWN}
+	}
+
+	self.health.mutex.Lock()
+	defer self.health.mutex.Unlock()
+
+	return self.health.cached
+}
+
+// run runs checks and caches their result, then wakes probes that wait for it
+func (self *healthChecker) run() {
+	self.mutex.Lock()
+	checks := make(map[string]Check, len(self.checks))
+	for name, check := range self.checks {
+		checks[name] = check
+	}
+	self.mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), self.timeout)
+	defer cancel()
+
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make([]CheckResult, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, check)
+		}(i, checks[name])
+	}
+	wg.Wait()
+
+	now := time.Now()
+	health := Health{Status: HEALTH_UP, Checks: make(map[string]CheckResult), CheckedAt: &now}
+	for i, name := range names {
+		health.Checks[name] = results[i]
+		if results[i].Status != HEALTH_UP {
+			health.Status = HEALTH_DOWN
+		}
+	}
+
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	self.cached = health
+	self.expiresAt = now.Add(self.ttl)
+	close(self.running)
+	self.running = nil
+}
+
+// runCheck runs check until it returns or its context times out, panic of check fails it
+func runCheck(ctx context.Context, check Check) CheckResult {
+	beginTime := time.Now()
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("%v", r)
+			}
+		}()
+		done <- check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := CheckResult{
+		Status:    HEALTH_UP,
+		LatencyMs: float64(time.Since(beginTime).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = HEALTH_DOWN
+		result.Error = err.Error()
+	}
+
+	return result
+}
@@ -0,0 +1,76 @@
+package application
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert"
+	"github.com/pkg/errors"
+)
+
+func TestReadyIsNotFailedByCancelledProbe(t *testing.T) {
+	app := newTestApplication(t)
+	app.RegisterCheck("sql", func(ctx context.Context) error {
+		select {
+		case <-time.After(50 * time.Millisecond):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, HEALTH_DOWN, app.Ready(ctx).Status)
+
+	health := app.Ready(context.Background())
+	assert.Equal(t, HEALTH_UP, health.Status)
+	assert.Equal(t, HEALTH_UP, health.Checks["sql"].Status)
+}
+
+func TestReadySharesRunOfChecks(t *testing.T) {
+	app := newTestApplication(t)
+	var runs int32
+	app.RegisterCheck("sql", func(ctx context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		time.Sleep(20 * time.Millisecond)
+		return errors.New("refused")
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			health := app.Ready(context.Background())
+			assert.Equal(t, HEALTH_DOWN, health.Status)
+			assert.Equal(t, "refused", health.Checks["sql"].Error)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&runs))
+
+	// result is cached for ttl
+	app.Ready(context.Background())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&runs))
+}
+
+func TestReadyTimesOutChecks(t *testing.T) {
+	app := newTestApplication(t)
+	app.health.timeout = 20 * time.Millisecond
+	app.RegisterCheck("couchbase", func(ctx context.Context) error {
+		select {}
+	})
+	app.RegisterCheck("sql", func(ctx context.Context) error { return nil })
+
+	health := app.Ready(context.Background())
+	assert.Equal(t, HEALTH_DOWN, health.Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), health.Checks["couchbase"].Error)
+	assert.Equal(t, HEALTH_UP, health.Checks["sql"].Status)
+
+	app.Drain()
+	assert.Equal(t, HEALTH_DRAINING, app.Ready(context.Background()).Status)
+}
@@ -0,0 +1,291 @@
+package application
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"sort"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+
+	"microtecture/infrastructure/config"
+)
+
+// signingMethodEdDSA is Ed25519 signing method that jwt-go does not ship
+type signingMethodEdDSA struct{}
+
+func (self signingMethodEdDSA) Alg() string {
+	return config.EDDSA
+}
+
+func (self signingMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+
+	return jwt.EncodeSegment(ed25519.Sign(privateKey, []byte(signingString))), nil
+}
+
+func (self signingMethodEdDSA) Verify(signingString, signature string, key interface{}) error {
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(publicKey, []byte(signingString), sig) {
+		return jwt.ErrSignatureInvalid
+	}
+
+	return nil
+}
+
+type signingKey struct {
+	kid        string
+	method     jwt.SigningMethod
+	privateKey interface{}
+	publicKey  interface{}
+}
+
+// keySet holds signing key and every key that access tokens are verified with
+type keySet struct {
+	signing *signingKey
+	keys    map[string]*signingKey
+}
+
+func newKeySet(conf config.ApplicationConfig) (keySet, error) {
+	method, ok := jwtSigningMethods[conf.JWT.Algorithm]
+	if !ok {
+		return keySet{}, errors.New("jwt algorithm " + conf.JWT.Algorithm + " is not supported")
+	}
+
+	if _, ok := method.(*jwt.SigningMethodHMAC); ok {
+		secret := []byte(conf.JWT.Secret)
+		key := &signingKey{method: method, privateKey: secret, publicKey: secret}
+		return keySet{signing: key, keys: map[string]*signingKey{"": key}}, nil
+	}
+
+	set := keySet{keys: make(map[string]*signingKey)}
+	for _, k := range conf.JWT.Keys {
+		key := &signingKey{kid: k.Kid, method: method}
+
+		if k.PrivateKey != "" {
+			privateKey, publicKey, err := readPrivateKey(k.PrivateKey, conf.JWT.Algorithm)
+			if err != nil {
+				return keySet{}, errors.New(k.Kid + ": " + err.Error())
+			}
+			key.privateKey = privateKey
+			key.publicKey = publicKey
+		}
+
+		if k.PublicKey != "" {
+			publicKey, err := readPublicKey(k.PublicKey, conf.JWT.Algorithm)
+			if err != nil {
+				return keySet{}, errors.New(k.Kid + ": " + err.Error())
+			}
+			key.publicKey = publicKey
+		}
+
+		set.keys[k.Kid] = key
+		if k.Kid == conf.JWT.SigningKey {
+			set.signing = key
+		}
+	}
+
+	return set, nil
+}
+
+// verificationKey returns public key of token by its kid header
+func (self keySet) verificationKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := self.keys[kid]
+	if !ok {
+		return nil, errors.New("jwt kid " + kid + " is unknown")
+	}
+
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, errors.New("jwt algorithm " + token.Method.Alg() + " is not expected")
+	}
+
+	return key.publicKey, nil
+}
+
+func readPEM(path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.New(err.Error())
+	}
+
+	return data, nil
+}
+
+func readPrivateKey(path, algorithm string) (interface{}, interface{}, error) {
+	data, err := readPEM(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch algorithm {
+	case config.RS256, config.RS384, config.RS512:
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, nil, errors.New(err.Error())
+		}
+		return key, &key.PublicKey, nil
+	case config.ES256, config.ES384, config.ES512:
+		key, err := jwt.ParseECPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, nil, errors.New(err.Error())
+		}
+		if err := checkCurve(&key.PublicKey, algorithm); err != nil {
+			return nil, nil, err
+		}
+		return key, &key.PublicKey, nil
+	case config.EDDSA:
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, nil, errors.New("key must be PEM encoded")
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, nil, errors.New(err.Error())
+		}
+		key, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return nil, nil, errors.New("key is not a valid Ed25519 private key")
+		}
+		return key, key.Public(), nil
+	}
+
+	return nil, nil, errors.New("jwt algorithm " + algorithm + " has no private key")
+}
+
+func readPublicKey(path, algorithm string) (interface{}, error) {
+	data, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch algorithm {
+	case config.RS256, config.RS384, config.RS512:
+		key, err := jwt.ParseRSAPublicKeyFromPEM(data)
+		if err != nil {
+			return nil, errors.New(err.Error())
+		}
+		return key, nil
+	case config.ES256, config.ES384, config.ES512:
+		key, err := jwt.ParseECPublicKeyFromPEM(data)
+		if err != nil {
+			return nil, errors.New(err.Error())
+		}
+		if err := checkCurve(key, algorithm); err != nil {
+			return nil, err
+		}
+		return key, nil
+	case config.EDDSA:
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("key must be PEM encoded")
+		}
+		parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, errors.New(err.Error())
+		}
+		key, ok := parsed.(ed25519.PublicKey)
+		if !ok {
+			return nil, errors.New("key is not a valid Ed25519 public key")
+		}
+		return key, nil
+	}
+
+	return nil, errors.New("jwt algorithm " + algorithm + " has no public key")
+}
+
+func checkCurve(key *ecdsa.PublicKey, algorithm string) error {
+	method := jwtSigningMethods[algorithm].(*jwt.SigningMethodECDSA)
+	if key.Curve.Params().BitSize != method.CurveBits {
+		return errors.New("key curve does not match " + algorithm)
+	}
+
+	return nil
+}
+
+// JWK is json web key of RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS is json web key set of RFC 7517
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns public keys of access tokens sorted by kid so responses are stable for caches,
+// empty for hmac algorithms
+func (self application) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	encode := base64.RawURLEncoding.EncodeToString
+
+	kids := make([]string, 0, len(self.keys.keys))
+	for kid := range self.keys.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	for _, kid := range kids {
+		key := self.keys.keys[kid]
+		jwk := JWK{Use: "sig", Kid: key.kid, Alg: key.method.Alg()}
+
+		switch pub := key.publicKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = encode(pub.N.Bytes())
+			jwk.E = encode(big.NewInt(int64(pub.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			jwk.Kty = "EC"
+			jwk.Crv = pub.Curve.Params().Name
+			jwk.X = encode(padLeft(pub.X.Bytes(), size))
+			jwk.Y = encode(padLeft(pub.Y.Bytes(), size))
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = encode(pub)
+		default:
+			continue
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	return jwks
+}
+
+func padLeft(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+
+	padded := make([]byte, size)
+	copy(padded[size-len(b):], b)
+	return padded
+}
@@ -0,0 +1,212 @@
+package application
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/assert"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v2"
+
+	"microtecture/infrastructure/config"
+)
+
+// writeTestPEM writes der as PEM block of type to a file of dir and returns its path
+func writeTestPEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	path := filepath.Join(dir, name)
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	assert.NoError(t, ioutil.WriteFile(path, data, 0600))
+
+	return path
+}
+
+// writeTestKeys generates a key pair of algorithm and returns its private key
+// and paths of its PEM encoded private and public keys
+func writeTestKeys(t *testing.T, dir, kid, algorithm string) (interface{}, string, string) {
+	var privateKey, publicKey interface{}
+	var privateDER []byte
+	var privateType string
+	var err error
+
+	switch algorithm {
+	case config.RS256:
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		assert.NoError(t, err)
+		privateKey, publicKey = key, &key.PublicKey
+		privateDER, privateType = x509.MarshalPKCS1PrivateKey(key), "RSA PRIVATE KEY"
+	case config.ES256:
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.NoError(t, err)
+		privateKey, publicKey = key, &key.PublicKey
+		privateDER, err = x509.MarshalECPrivateKey(key)
+		assert.NoError(t, err)
+		privateType = "EC PRIVATE KEY"
+	case config.EDDSA:
+		public, key, err := ed25519.GenerateKey(rand.Reader)
+		assert.NoError(t, err)
+		privateKey, publicKey = key, public
+		privateDER, err = x509.MarshalPKCS8PrivateKey(key)
+		assert.NoError(t, err)
+		privateType = "PRIVATE KEY"
+	default:
+		t.Fatal("algorithm " + algorithm + " has no test keys")
+	}
+
+	publicDER, err := x509.MarshalPKIXPublicKey(publicKey)
+	assert.NoError(t, err)
+
+	return privateKey,
+		writeTestPEM(t, dir, kid+".key", privateType, privateDER),
+		writeTestPEM(t, dir, kid+".pub", "PUBLIC KEY", publicDER)
+}
+
+// newTestKeyApplication returns application that signs with key new of algorithm and
+// verifies with it and with public key old, it returns private key of old
+func newTestKeyApplication(t *testing.T, algorithm string) (application, interface{}) {
+	dir, err := ioutil.TempDir("", "keys")
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	oldKey, _, oldPublic := writeTestKeys(t, dir, "old", algorithm)
+	_, newPrivate, newPublic := writeTestKeys(t, dir, "new", algorithm)
+
+	conf := config.ApplicationConfig{}
+	keysYAML := fmt.Sprintf(`
+algorithm: %s
+max_age: 900
+signing_key: new
+keys:
+  - kid: old
+    public_key: %s
+  - kid: new
+    private_key: %s
+    public_key: %s
+`, algorithm, oldPublic, newPrivate, newPublic)
+	assert.NoError(t, yaml.Unmarshal([]byte(keysYAML), &conf.JWT))
+
+	keys, err := newKeySet(conf)
+	assert.NoError(t, err)
+
+	return application{Config: conf, keys: keys}, oldKey
+}
+
+func TestKeysSignAndVerifyByKid(t *testing.T) {
+	for _, algorithm := range []string{config.RS256, config.ES256, config.EDDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			app, oldKey := newTestKeyApplication(t, algorithm)
+
+			tokenString, err := app.CreateJWT(uuid.New(), "first", "last", false, "admin")
+			assert.NoError(t, err)
+			token, err := jwt.ParseWithClaims(tokenString, &Claims{}, app.keys.verificationKey)
+			assert.NoError(t, err)
+			assert.Equal(t, "new", token.Header["kid"])
+			assert.Equal(t, []string{"admin"}, token.Claims.(*Claims).Roles)
+
+			// tokens of rotated key are verified by its public key
+			old := jwt.NewWithClaims(jwtSigningMethods[algorithm], &Claims{})
+			old.Header["kid"] = "old"
+			tokenString, err = old.SignedString(oldKey)
+			assert.NoError(t, err)
+			_, err = jwt.ParseWithClaims(tokenString, &Claims{}, app.keys.verificationKey)
+			assert.NoError(t, err)
+
+			old.Header["kid"] = "unknown"
+			tokenString, err = old.SignedString(oldKey)
+			assert.NoError(t, err)
+			_, err = jwt.ParseWithClaims(tokenString, &Claims{}, app.keys.verificationKey)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), "jwt kid unknown is unknown")
+		})
+	}
+}
+
+func TestVerificationKeyRejectsOtherAlgorithm(t *testing.T) {
+	app, _ := newTestKeyApplication(t, config.ES256)
+
+	// public key must not be used as hmac secret of a forged token
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{})
+	forged.Header["kid"] = "new"
+	tokenString, err := forged.SignedString([]byte("secret"))
+	assert.NoError(t, err)
+
+	_, err = jwt.ParseWithClaims(tokenString, &Claims{}, app.keys.verificationKey)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "jwt algorithm HS256 is not expected")
+}
+
+func TestReadKeyRejectsOtherCurve(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keys")
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	_, private, public := writeTestKeys(t, dir, "p256", config.ES256)
+
+	_, _, err = readPrivateKey(private, config.ES384)
+	assert.EqualError(t, err, "key curve does not match ES384")
+	_, err = readPublicKey(public, config.ES384)
+	assert.EqualError(t, err, "key curve does not match ES384")
+
+	// PEM of another algorithm is rejected
+	_, err = readPublicKey(public, config.EDDSA)
+	assert.EqualError(t, err, "key is not a valid Ed25519 public key")
+}
+
+func TestJWKSEncodesKeysSortedByKid(t *testing.T) {
+	encode := base64.RawURLEncoding.EncodeToString
+
+	app, _ := newTestKeyApplication(t, config.RS256)
+	jwks := app.JWKS()
+	assert.Equal(t, 2, len(jwks.Keys))
+	assert.Equal(t, "new", jwks.Keys[0].Kid)
+	assert.Equal(t, "old", jwks.Keys[1].Kid)
+	public := app.keys.keys["new"].publicKey.(*rsa.PublicKey)
+	assert.Equal(t, JWK{
+		Kty: "RSA",
+		Use: "sig",
+		Kid: "new",
+		Alg: config.RS256,
+		N:   encode(public.N.Bytes()),
+		E:   "AQAB",
+	}, jwks.Keys[0])
+
+	app, _ = newTestKeyApplication(t, config.ES256)
+	jwk := app.JWKS().Keys[0]
+	ec := app.keys.keys["new"].publicKey.(*ecdsa.PublicKey)
+	x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	assert.NoError(t, err)
+	y, err := base64.RawURLEncoding.DecodeString(jwk.Y)
+	assert.NoError(t, err)
+	assert.Equal(t, "EC", jwk.Kty)
+	assert.Equal(t, "P-256", jwk.Crv)
+	assert.Equal(t, 32, len(x))
+	assert.Equal(t, 32, len(y))
+	assert.Equal(t, 0, ec.X.Cmp(new(big.Int).SetBytes(x)))
+	assert.Equal(t, 0, ec.Y.Cmp(new(big.Int).SetBytes(y)))
+
+	app, _ = newTestKeyApplication(t, config.EDDSA)
+	jwk = app.JWKS().Keys[0]
+	assert.Equal(t, "OKP", jwk.Kty)
+	assert.Equal(t, "Ed25519", jwk.Crv)
+	assert.Equal(t, encode(app.keys.keys["new"].publicKey.(ed25519.PublicKey)), jwk.X)
+
+	// hmac secrets are not published
+	assert.Equal(t, 0, len(newTestApplication(t).JWKS().Keys))
+}
+
+func TestPadLeft(t *testing.T) {
+	assert.Equal(t, []byte{0, 0, 1, 2}, padLeft([]byte{1, 2}, 4))
+	assert.Equal(t, []byte{1, 2, 3}, padLeft([]byte{1, 2, 3}, 2))
+}
@@ -0,0 +1,20 @@
+package application
+
+import (
+	"time"
+
+	"microtecture/infrastructure/metrics"
+)
+
+// Measure records count and latency of request by its route, method and status code
+func (self RestController) Measure(f Action) Action {
+	return func(ctx *Context) error {
+		beginTime := time.Now()
+
+		defer func() {
+			metrics.ObserveRequest(ctx.Route(), ctx.Request.Method, ctx.StatusCode(), time.Since(beginTime))
+		}()
+
+		return f(ctx)
+	}
+}
@@ -0,0 +1,100 @@
+package application
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/alecthomas/assert"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"microtecture/infrastructure/config"
+	"microtecture/infrastructure/datastore"
+	"microtecture/infrastructure/metrics"
+)
+
+// scrapeMetric returns value of metric whose labels have values from metrics handler,
+// sample count for histograms and 0 if there is no such metric
+func scrapeMetric(t *testing.T, name string, labels map[string]string) float64 {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	metrics.Handler(metrics.NewRegistry(datastore.Session{})).ServeHTTP(recorder, request)
+
+	parser := expfmt.TextParser{}
+	families, err := parser.TextToMetricFamilies(recorder.Body)
+	assert.NoError(t, err)
+
+	family, ok := families[name]
+	if !ok {
+		return 0
+	}
+	for _, m := range family.Metric {
+		if hasLabels(m, labels) {
+			if family.GetType() == dto.MetricType_HISTOGRAM {
+				return float64(m.GetHistogram().GetSampleCount())
+			}
+			if family.GetType() == dto.MetricType_GAUGE {
+				return m.GetGauge().GetValue()
+			}
+			return m.GetCounter().GetValue()
+		}
+	}
+
+	return 0
+}
+
+func hasLabels(m *dto.Metric, labels map[string]string) bool {
+	matched := 0
+	for _, label := range m.Label {
+		if value, ok := labels[label.GetName()]; ok && value == label.GetValue() {
+			matched++
+		}
+	}
+
+	return matched == len(labels)
+}
+
+func TestMetricsOfRequests(t *testing.T) {
+	app := newTestApplication(t)
+	user := createTestUser(t, app)
+	adminToken, err := app.CreateJWT(user.Id, "", "", false, "admin")
+	assert.NoError(t, err)
+	userToken, err := app.CreateJWT(user.Id, "", "", false)
+	assert.NoError(t, err)
+
+	const route = "/api/v1/metrics-test/:id"
+	controller := NewRestController(Controller{Application: app})
+	handler := WithRoute(route, controller.Handle(app.Authorize(func(ctx *Context) error {
+		return ctx.NoContent()
+	}, AnyRole("admin"))))
+
+	outcome := func(outcome string) float64 {
+		return scrapeMetric(t, "microtecture_auth_outcomes_total", map[string]string{"outcome": outcome})
+	}
+	success, forbidden, unauthorized := outcome(metrics.AUTH_SUCCESS), outcome(metrics.AUTH_FORBIDDEN),
+		outcome(metrics.AUTH_UNAUTHORIZED)
+
+	for _, token := range []string{adminToken, userToken, ""} {
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/metrics-test/1", nil)
+		if token != "" {
+			request.Header.Set(config.AUTHORZIATION_NAME, "Bearer "+token)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	for status, count := range map[string]float64{"204": 1, "403": 1, "401": 1} {
+		labels := map[string]string{"route": route, "method": http.MethodGet, "status": status}
+		assert.Equal(t, count, scrapeMetric(t, "microtecture_http_requests_total", labels), status)
+		assert.Equal(t, count, scrapeMetric(t, "microtecture_http_request_duration_seconds", labels), status)
+	}
+
+	// policy denials are forbidden outcomes
+	assert.Equal(t, success+1, outcome(metrics.AUTH_SUCCESS))
+	assert.Equal(t, forbidden+1, outcome(metrics.AUTH_FORBIDDEN))
+	assert.Equal(t, unauthorized+1, outcome(metrics.AUTH_UNAUTHORIZED))
+
+	buildInfo := map[string]string{"version": config.VERSION, "goversion": runtime.Version()}
+	assert.Equal(t, float64(1), scrapeMetric(t, "microtecture_build_info", buildInfo))
+}
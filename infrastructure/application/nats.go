@@ -0,0 +1,446 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"microtecture/infrastructure/tracing"
+)
+
+const (
+	// NATS_STATUS_HEADER is status code of a failed reply, replies without it are successful
+	NATS_STATUS_HEADER = "Nats-Service-Error-Code"
+)
+
+// MessageContext is context of a nats message
+type MessageContext struct {
+	Msg       *nats.Msg
+	Subject   string
+	Header    nats.Header
+	RequestID string
+	Logger    logrus.FieldLogger
+	ctx       context.Context
+	durable   bool
+	settled   bool
+}
+
+// newMessageContext creates and returns MessageContext of message
+func newMessageContext(msg *nats.Msg) *MessageContext {
+	header := msg.Header
+	if header == nil {
+		header = nats.Header{}
+	}
+
+	return &MessageContext{
+		Msg:     msg,
+		Subject: msg.Subject,
+		Header:  header,
+		Logger:  logrus.StandardLogger(),
+		ctx:     context.Background(),
+	}
+}
+
+// Context returns context of message, it carries span of message
+func (self *MessageContext) Context() context.Context {
+	return self.ctx
+}
+
+// IsRequest reports whether message waits for a reply, reply subject of jetstream
+// messages is for acknowledgement
+func (self *MessageContext) IsRequest() bool {
+	return !self.durable && self.Msg.Reply != ""
+}
+
+// Decode decodes json payload of message to v and validates it by its validate tags,
+// unknown fields and trailing data are rejected
+func (self *MessageContext) Decode(v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(self.Msg.Data))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		return NewErrValidation(err.Error()).WithCode(CODE_MALFORMED_BODY)
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		return NewErrValidation("message must have only one json value").WithCode(CODE_MALFORMED_BODY)
+	}
+
+	return Validate(v)
+}
+
+// Respond replies v as json to request message
+func (self *MessageContext) Respond(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return errors.New(err.Error())
+	}
+
+	return self.reply(data, "application/json", nil)
+}
+
+func (self *MessageContext) reply(data []byte, contentType string, header nats.Header) error {
+	if !self.IsRequest() {
+		return nil
+	}
+
+	if header == nil {
+		header = nats.Header{}
+	}
+	header.Set("Content-Type", contentType)
+	header.Set(REQUEST_ID_HEADER, self.RequestID)
+
+	err := self.Msg.RespondMsg(&nats.Msg{Subject: self.Msg.Reply, Header: header, Data: data})
+	return HandleError(err)
+}
+
+// MessageAction is handler of a nats message
+type MessageAction func(*MessageContext) error
+
+// MessageMiddleware wraps a message action to run something before or after it
+type MessageMiddleware func(MessageAction) MessageAction
+
+type NatsController struct {
+	Controller
+	middlewares []MessageMiddleware
+}
+
+// NewNatsController creates and returns nats controller with default middlewares
+// that set request id, trace messages, set logger, log messages and recover panics,
+// errors of handlers are replied as problem json like errors of rest controller
+func NewNatsController(controller Controller) NatsController {
+	self := NatsController{Controller: controller}
+	self.middlewares = []MessageMiddleware{
+		self.RequestID,
+		self.Trace,
+		self.RequestLogger,
+		self.LogMessage,
+		self.WriteError,
+		self.RecoverPanic,
+	}
+
+	return self
+}
+
+// Use returns copy of controller that runs middlewares after its own ones
+func (self NatsController) Use(middlewares ...MessageMiddleware) NatsController {
+	chain := make([]MessageMiddleware, 0, len(self.middlewares)+len(middlewares))
+	chain = append(chain, self.middlewares...)
+	chain = append(chain, middlewares...)
+	self.middlewares = chain
+
+	return self
+}
+
+// Handle returns nats handler of action wrapped in middlewares of controller,
+// then in subject middlewares
+func (self NatsController) Handle(f MessageAction, middlewares ...MessageMiddleware) nats.MsgHandler {
+	action := self.chain(f, middlewares...)
+
+	return func(msg *nats.Msg) {
+		self.dispatch(newMessageContext(msg), action)
+	}
+}
+
+func (self NatsController) chain(f MessageAction, middlewares ...MessageMiddleware) MessageAction {
+	action := f
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		action = middlewares[i](action)
+	}
+	for i := len(self.middlewares) - 1; i >= 0; i-- {
+		action = self.middlewares[i](action)
+	}
+
+	return action
+}
+
+func (self NatsController) dispatch(ctx *MessageContext, action MessageAction) {
+	if err := action(ctx); err != nil {
+		self.writeError(ctx, err)
+	}
+
+	if ctx.durable && !ctx.settled {
+		if err := ctx.Msg.Ack(); err != nil {
+			ctx.Logger.Error(fmt.Sprintf("%+v\n", errors.New(err.Error())))
+		}
+	}
+}
+
+// conn returns nats connection of application
+func (self NatsController) conn() (*nats.Conn, error) {
+	if self.Application.Nats == nil {
+		return nil, errors.New("nats is not configured")
+	}
+
+	return self.Application.Nats, nil
+}
+
+// Subscribe handles messages of subject in queue group of config,
+// so one instance of application handles each message
+func (self NatsController) Subscribe(subject string, f MessageAction, middlewares ...MessageMiddleware) error {
+	return self.SubscribeQueue(subject, self.Application.Config.NATS.QueueGroup, f, middlewares...)
+}
+
+// SubscribeQueue handles messages of subject in queue group,
+// every instance handles each message if queue is empty
+func (self NatsController) SubscribeQueue(
+	subject, queue string, f MessageAction, middlewares ...MessageMiddleware,
+) error {
+	conn, err := self.conn()
+	if err != nil {
+		return err
+	}
+
+	if _, err := conn.QueueSubscribe(subject, queue, self.Handle(f, middlewares...)); err != nil {
+		return errors.New(err.Error())
+	}
+
+	return nil
+}
+
+// Consume handles messages of subject in jetstream stream by durable consumer, it is created
+// if it does not exist. messages are acknowledged if handler succeeds, terminated if it returns
+// a client error and redelivered after ack wait of config otherwise, up to max deliver times.
+// consumer survives restart of application.
+func (self NatsController) Consume(
+	stream, subject, durable string, f MessageAction, middlewares ...MessageMiddleware,
+) error {
+	conn, err := self.conn()
+	if err != nil {
+		return err
+	}
+
+	js, err := conn.JetStream()
+	if err != nil {
+		return errors.New(err.Error())
+	}
+
+	// consumers that subscription creates are deleted on drain, so it is created first
+	if _, err := js.ConsumerInfo(stream, durable); errors.Is(err, nats.ErrConsumerNotFound) {
+		_, err = js.AddConsumer(stream, &nats.ConsumerConfig{
+			Durable:        durable,
+			DeliverSubject: nats.NewInbox(),
+			DeliverGroup:   durable,
+			FilterSubject:  subject,
+			DeliverPolicy:  nats.DeliverAllPolicy,
+			AckPolicy:      nats.AckExplicitPolicy,
+			AckWait:        time.Duration(self.Application.Config.NATS.AckWait) * time.Second,
+			MaxDeliver:     int(self.Application.Config.NATS.MaxDeliver),
+		})
+		if err != nil {
+			// another instance may have created it meanwhile
+			if _, e := js.ConsumerInfo(stream, durable); e != nil {
+				return errors.New(err.Error())
+			}
+		}
+	} else if err != nil {
+		return errors.New(err.Error())
+	}
+
+	action := self.chain(f, middlewares...)
+	_, err = js.QueueSubscribe(subject, durable, func(msg *nats.Msg) {
+		ctx := newMessageContext(msg)
+		ctx.durable = true
+		self.dispatch(ctx, action)
+	}, nats.Bind(stream, durable), nats.ManualAck())
+	if err != nil {
+		return errors.New(err.Error())
+	}
+
+	return nil
+}
+
+// Drain stops handlers from taking new messages, waits for them to finish taken ones
+// until drain timeout of config and closes connection
+func (self NatsController) Drain() error {
+	conn, err := self.conn()
+	if err != nil {
+		return err
+	}
+
+	if err := conn.Drain(); err != nil {
+		return errors.New(err.Error())
+	}
+
+	timeout := time.Duration(self.Application.Config.NATS.DrainTimeout)*time.Second + time.Second
+	deadline := time.Now().Add(timeout)
+	for !conn.IsClosed() {
+		if time.Now().After(deadline) {
+			conn.Close()
+			return errors.New("nats drain timed out")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	return nil
+}
+
+// RequestID sets request id of context from X-Request-ID header of message,
+// or a generated one if it is missing or malformed
+func (self NatsController) RequestID(f MessageAction) MessageAction {
+	return func(ctx *MessageContext) error {
+		id := ctx.Header.Get(REQUEST_ID_HEADER)
+		if len(id) > MAX_REQUEST_ID_LENGTH || !requestIDPattern.MatchString(id) {
+			id = uuid.New().String()
+		}
+		ctx.RequestID = id
+		ctx.ctx = context.WithValue(ctx.ctx, requestIDKey{}, id)
+
+		return f(ctx)
+	}
+}
+
+// Trace opens consumer span of message as child of trace context of its header
+func (self NatsController) Trace(f MessageAction) MessageAction {
+	return func(ctx *MessageContext) error {
+		parent := otel.GetTextMapPropagator().Extract(
+			ctx.ctx, propagation.HeaderCarrier(ctx.Header),
+		)
+
+		spanContext, span := tracing.Tracer().Start(
+			parent,
+			ctx.Subject+" process",
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(
+				semconv.MessagingSystemKey.String("nats"),
+				semconv.MessagingDestinationKey.String(ctx.Subject),
+				semconv.MessagingOperationProcess,
+				semconv.MessagingMessagePayloadSizeBytesKey.Int(len(ctx.Msg.Data)),
+				attribute.String("messaging.request_id", ctx.RequestID),
+			),
+		)
+		defer span.End()
+		ctx.ctx = spanContext
+
+		// WriteError records errors on span, as it handles them inside this span
+		return f(ctx)
+	}
+}
+
+// RequestLogger sets logger of context with subject, request id and trace id of message
+func (self NatsController) RequestLogger(f MessageAction) MessageAction {
+	return func(ctx *MessageContext) error {
+		fields := logrus.Fields{
+			"subject":    ctx.Subject,
+			"request_id": ctx.RequestID,
+		}
+		if spanContext := trace.SpanContextFromContext(ctx.ctx); spanContext.IsValid() {
+			fields["trace_id"] = spanContext.TraceID().String()
+		}
+		ctx.Logger = self.Application.Logger.WithFields(fields)
+
+		return f(ctx)
+	}
+}
+
+// LogMessage logs subject and duration of message with logger of context
+func (self NatsController) LogMessage(f MessageAction) MessageAction {
+	return func(ctx *MessageContext) error {
+		beginTime := time.Now()
+
+		err := f(ctx)
+
+		ctx.Logger.WithField("duration", time.Since(beginTime)).Info(ctx.Subject)
+
+		return err
+	}
+}
+
+// WriteError replies error of action to request as problem json, or logs it if message
+// is not a request, and records it on span of message. durable messages are terminated
+// on client errors and are left unacknowledged otherwise, so they are redelivered after ack wait.
+func (self NatsController) WriteError(f MessageAction) MessageAction {
+	return func(ctx *MessageContext) error {
+		if err := f(ctx); err != nil {
+			self.writeError(ctx, err)
+		}
+
+		return nil
+	}
+}
+
+func (self NatsController) writeError(ctx *MessageContext, err error) {
+	e, ok := mapError(err).(ErrHTTP)
+	if !ok {
+		ctx.Logger.Error(fmt.Sprintf("%+v\n", err))
+		e = NewErrCustom(
+			http.StatusInternalServerError,
+			http.StatusText(http.StatusInternalServerError),
+		).WithCode(CODE_INTERNAL)
+	} else if !ctx.IsRequest() {
+		ctx.Logger.Warning(e.Error())
+	}
+	ctx.traceError(err, e)
+
+	if ctx.durable && !ctx.settled {
+		ctx.settled = true
+		if e.Code() >= http.StatusInternalServerError {
+			// nak redelivers at once, so failing message waits for ack wait instead
+			if meta, err := ctx.Msg.Metadata(); err == nil &&
+				meta.NumDelivered >= uint64(self.Application.Config.NATS.MaxDeliver) {
+				ctx.Logger.Errorf("message is dropped after %d deliveries", meta.NumDelivered)
+			}
+			return
+		}
+		if err := ctx.Msg.Term(); err != nil {
+			ctx.Logger.Error(fmt.Sprintf("%+v\n", errors.New(err.Error())))
+		}
+		return
+	}
+
+	problem := NewProblem(e)
+	problem.Instance = ctx.Subject
+	problem.RequestID = ctx.RequestID
+
+	data, err := json.Marshal(problem)
+	if err != nil {
+		ctx.Logger.Error(fmt.Sprintf("%+v\n", errors.New(err.Error())))
+		return
+	}
+
+	header := nats.Header{}
+	header.Set(NATS_STATUS_HEADER, strconv.Itoa(problem.Status))
+	if err := ctx.reply(data, PROBLEM_CONTENT_TYPE, header); err != nil {
+		ctx.Logger.Error(fmt.Sprintf("%+v\n", err))
+	}
+}
+
+// traceError records error of message on its span, server errors fail span
+func (self *MessageContext) traceError(err error, e ErrHTTP) {
+	span := trace.SpanFromContext(self.ctx)
+	span.RecordError(err)
+	span.SetAttributes(attribute.Int("messaging.status_code", e.Code()), attribute.String("error.code", ErrorCode(e)))
+	if e.Code() >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// RecoverPanic recovers panic of action and returns it as internal error
+func (self NatsController) RecoverPanic(f MessageAction) MessageAction {
+	return func(ctx *MessageContext) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("%v: %s", r, debug.Stack())
+			}
+		}()
+
+		return f(ctx)
+	}
+}
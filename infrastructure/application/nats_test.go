@@ -0,0 +1,206 @@
+package application
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+)
+
+// newTestNats starts embedded nats server with jetstream and returns a connection to it
+func newTestNats(t *testing.T) (*server.Server, *nats.Conn) {
+	storeDir, err := ioutil.TempDir("", "nats")
+	assert.NoError(t, err)
+
+	s, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  storeDir,
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	assert.NoError(t, err)
+	go s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server is not ready")
+	}
+
+	conn, err := nats.Connect(s.ClientURL())
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		conn.Close()
+		s.Shutdown()
+		s.WaitForShutdown()
+		os.RemoveAll(storeDir)
+	})
+
+	return s, conn
+}
+
+func newTestNatsController(t *testing.T, s *server.Server) NatsController {
+	conn, err := nats.Connect(s.ClientURL())
+	assert.NoError(t, err)
+	t.Cleanup(conn.Close)
+
+	app := newTestApplication(t)
+	app.Nats = conn
+	app.Config.NATS.QueueGroup = "test"
+	app.Config.NATS.AckWait = 1
+	app.Config.NATS.MaxDeliver = 3
+
+	return NewNatsController(Controller{Application: app})
+}
+
+func TestNatsQueueGroupDelivery(t *testing.T) {
+	s, conn := newTestNats(t)
+
+	var queued, broadcast int32
+	for i := 0; i < 2; i++ {
+		n := newTestNatsController(t, s)
+		assert.NoError(t, n.Subscribe("test.queue", func(ctx *MessageContext) error {
+			atomic.AddInt32(&queued, 1)
+			return nil
+		}))
+		assert.NoError(t, n.SubscribeQueue("test.queue", "", func(ctx *MessageContext) error {
+			atomic.AddInt32(&broadcast, 1)
+			return nil
+		}))
+		assert.NoError(t, n.Application.Nats.Flush())
+	}
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, conn.Publish("test.queue", nil))
+	}
+	assert.NoError(t, conn.Flush())
+
+	deadline := time.Now().Add(2 * time.Second)
+	for (atomic.LoadInt32(&queued) < 10 || atomic.LoadInt32(&broadcast) < 20) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	// one instance of group handles each message, subscriptions without group get every one
+	assert.Equal(t, int32(10), atomic.LoadInt32(&queued))
+	assert.Equal(t, int32(20), atomic.LoadInt32(&broadcast))
+}
+
+func TestNatsRequestReply(t *testing.T) {
+	exporter := exportSpans(t)
+	s, conn := newTestNats(t)
+	n := newTestNatsController(t, s)
+
+	type request struct {
+		Id string `json:"id" validate:"required,uuid"`
+	}
+	assert.NoError(t, n.Subscribe("test.users.get", func(ctx *MessageContext) error {
+		r := request{}
+		if err := ctx.Decode(&r); err != nil {
+			return err
+		}
+		if r.Id == "00000000-0000-0000-0000-000000000000" {
+			return errors.New("connection refused")
+		}
+		return NewErrNotFound("user").WithCode(CODE_USER_NOT_FOUND)
+	}))
+	assert.NoError(t, n.Application.Nats.Flush())
+
+	msg := nats.NewMsg("test.users.get")
+	msg.Header.Set(REQUEST_ID_HEADER, "request-1")
+	msg.Data = []byte(`{"id":"3b241101-e2bb-4255-8caf-4136c566a962"}`)
+	reply, err := conn.RequestMsg(msg, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "404", reply.Header.Get(NATS_STATUS_HEADER))
+	assert.Equal(t, PROBLEM_CONTENT_TYPE, reply.Header.Get("Content-Type"))
+	assert.Equal(t, "request-1", reply.Header.Get(REQUEST_ID_HEADER))
+
+	problem := Problem{}
+	assert.NoError(t, json.Unmarshal(reply.Data, &problem))
+	assert.Equal(t, http.StatusNotFound, problem.Status)
+	assert.Equal(t, CODE_USER_NOT_FOUND, problem.Code)
+	assert.Equal(t, "test.users.get", problem.Instance)
+	assert.Equal(t, "request-1", problem.RequestID)
+
+	reply, err = conn.Request("test.users.get", []byte(`{"id":"1"}`), time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "400", reply.Header.Get(NATS_STATUS_HEADER))
+
+	reply, err = conn.Request("test.users.get", []byte(`{"id":"00000000-0000-0000-0000-000000000000"}`), time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "500", reply.Header.Get(NATS_STATUS_HEADER))
+
+	// errors are recorded on consumer spans, only server errors fail them
+	spans := exporter.GetSpans()
+	assert.Equal(t, 3, len(spans))
+	for i, status := range []string{"Unset", "Unset", "Error"} {
+		assert.Equal(t, "test.users.get process", spans[i].Name)
+		assert.Equal(t, status, spans[i].Status.Code.String())
+		assert.Equal(t, 1, len(spans[i].Events))
+		assert.Equal(t, "exception", spans[i].Events[0].Name)
+	}
+	assert.Equal(t, "404", spanAttribute(spans[0], "messaging.status_code"))
+	assert.Equal(t, CODE_USER_NOT_FOUND, spanAttribute(spans[0], "error.code"))
+}
+
+func TestNatsConsumeSettlesMessages(t *testing.T) {
+	s, conn := newTestNats(t)
+	js, err := conn.JetStream()
+	assert.NoError(t, err)
+	_, err = js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"test.events.>"}})
+	assert.NoError(t, err)
+
+	n := newTestNatsController(t, s)
+	mutex := &sync.Mutex{}
+	deliveries := map[string][]time.Time{}
+	assert.NoError(t, n.Consume("TEST", "test.events.>", "test", func(ctx *MessageContext) error {
+		mutex.Lock()
+		deliveries[ctx.Subject] = append(deliveries[ctx.Subject], time.Now())
+		count := len(deliveries[ctx.Subject])
+		mutex.Unlock()
+
+		switch ctx.Subject {
+		case "test.events.invalid":
+			return NewErrValidation("bad event")
+		case "test.events.flaky":
+			if count == 1 {
+				return errors.New("connection refused")
+			}
+		case "test.events.broken":
+			return errors.New("connection refused")
+		}
+		return nil
+	}))
+
+	for _, subject := range []string{"test.events.ok", "test.events.invalid", "test.events.flaky", "test.events.broken"} {
+		_, err := js.Publish(subject, nil)
+		assert.NoError(t, err)
+	}
+
+	// broken message is delivered max deliver times, ack wait apart
+	time.Sleep(3500 * time.Millisecond)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, 1, len(deliveries["test.events.ok"]))
+	assert.Equal(t, 1, len(deliveries["test.events.invalid"]))
+	assert.Equal(t, 2, len(deliveries["test.events.flaky"]))
+	assert.Equal(t, 3, len(deliveries["test.events.broken"]))
+
+	// server errors are not nak'ed, so redelivery waits for ack wait
+	flaky := deliveries["test.events.flaky"]
+	assert.True(t, flaky[1].Sub(flaky[0]) >= 900*time.Millisecond, "%s", flaky[1].Sub(flaky[0]))
+
+	info, err := js.ConsumerInfo("TEST", "test")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second, info.Config.AckWait)
+	assert.Equal(t, 3, info.Config.MaxDeliver)
+}
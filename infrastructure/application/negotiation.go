@@ -0,0 +1,175 @@
+package application
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	MIME_JSON     = "application/json"
+	MIME_MSGPACK  = "application/msgpack"
+	MIME_PROTOBUF = "application/x-protobuf"
+
+	CODE_NOT_ACCEPTABLE = "not_acceptable"
+)
+
+type encoder func(v interface{}) ([]byte, error)
+
+// encoders are response formats in order of preference when client accepts several
+var encoders = []struct {
+	contentType string
+	aliases     []string
+	encode      encoder
+}{
+	{MIME_JSON, []string{MIME_JSON}, encodeJSON},
+	{MIME_MSGPACK, []string{MIME_MSGPACK, "application/x-msgpack"}, encodeMsgpack},
+	{MIME_PROTOBUF, []string{MIME_PROTOBUF, "application/protobuf"}, encodeProtobuf},
+}
+
+func encodeJSON(v interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := json.NewEncoder(buf).Encode(v); err != nil {
+		return nil, errors.New(err.Error())
+	}
+
+	return buf.Bytes(), nil
+}
+
+func encodeMsgpack(v interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	encoder := msgpack.NewEncoder(buf)
+	encoder.SetCustomStructTag("json")
+	if err := encoder.Encode(v); err != nil {
+		return nil, errors.New(err.Error())
+	}
+
+	return buf.Bytes(), nil
+}
+
+func encodeProtobuf(v interface{}) ([]byte, error) {
+	body, err := proto.Marshal(v.(proto.Message))
+	if err != nil {
+		return nil, errors.New(err.Error())
+	}
+
+	return body, nil
+}
+
+type mediaRange struct {
+	mediaType   string
+	quality     float64
+	specificity int
+}
+
+// parseAccept returns media ranges of Accept header sorted by quality, then by specificity
+// like RFC 7231 section 5.3.2, ranges with zero quality are kept as they exclude formats
+func parseAccept(header string) []mediaRange {
+	ranges := []mediaRange{}
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+
+		specificity := 2
+		if mediaType == "*/*" {
+			specificity = 0
+		} else if strings.HasSuffix(mediaType, "/*") {
+			specificity = 1
+		}
+		ranges = append(ranges, mediaRange{mediaType, quality, specificity})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].quality != ranges[j].quality {
+			return ranges[i].quality > ranges[j].quality
+		}
+		return ranges[i].specificity > ranges[j].specificity
+	})
+
+	return ranges
+}
+
+// matches reports whether media range matches content type or one of its aliases
+func (self mediaRange) matches(contentType string, aliases []string) bool {
+	switch self.specificity {
+	case 0:
+		return true
+	case 1:
+		return strings.HasPrefix(contentType, strings.TrimSuffix(self.mediaType, "*"))
+	}
+
+	for _, alias := range aliases {
+		if self.mediaType == alias {
+			return true
+		}
+	}
+
+	return false
+}
+
+// negotiate encodes v in format that Accept header of request prefers, quality of a format
+// is quality of most specific range that matches it, ties go to more specific ranges, then
+// to order of encoders. protobuf is only for proto messages.
+func (self *Context) negotiate(v interface{}) (string, []byte, error) {
+	self.Response.Header().Add("Vary", "Accept")
+
+	accept := self.Request.Header.Get("Accept")
+	if accept == "" {
+		accept = "*/*"
+	}
+	ranges := parseAccept(accept)
+
+	_, isProto := v.(proto.Message)
+	best, bestQuality, bestSpecificity := -1, 0.0, -1
+	for i, e := range encoders {
+		if e.contentType == MIME_PROTOBUF && !isProto {
+			continue
+		}
+
+		var matched *mediaRange
+		for j := range ranges {
+			if ranges[j].matches(e.contentType, e.aliases) &&
+				(matched == nil || ranges[j].specificity > matched.specificity) {
+				matched = &ranges[j]
+			}
+		}
+		if matched == nil || matched.quality <= 0 {
+			continue
+		}
+
+		if matched.quality > bestQuality ||
+			(matched.quality == bestQuality && matched.specificity > bestSpecificity) {
+			best, bestQuality, bestSpecificity = i, matched.quality, matched.specificity
+		}
+	}
+
+	if best >= 0 {
+		body, err := encoders[best].encode(v)
+		if err != nil {
+			return "", nil, err
+		}
+		return encoders[best].contentType, body, nil
+	}
+
+	return "", nil, NewErrCustom(
+		http.StatusNotAcceptable,
+		"response is not available in "+accept,
+	).WithCode(CODE_NOT_ACCEPTABLE)
+}
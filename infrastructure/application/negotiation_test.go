@@ -0,0 +1,56 @@
+package application
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alecthomas/assert"
+)
+
+func TestParseAcceptBreaksTiesBySpecificity(t *testing.T) {
+	ranges := parseAccept("*/*, application/*, application/msgpack, text/html;q=0.5")
+
+	types := []string{}
+	for _, r := range ranges {
+		types = append(types, r.mediaType)
+	}
+	assert.Equal(t, []string{"application/msgpack", "application/*", "*/*", "text/html"}, types)
+}
+
+func TestNegotiate(t *testing.T) {
+	cases := []struct {
+		accept      string
+		contentType string
+	}{
+		{"", MIME_JSON},
+		{"*/*", MIME_JSON},
+		{"*/*, application/msgpack", MIME_MSGPACK},
+		{"application/*, application/x-msgpack", MIME_MSGPACK},
+		{"application/json;q=0.1, */*", MIME_MSGPACK},
+		{"application/json;q=0.5, application/msgpack;q=0.8", MIME_MSGPACK},
+		{"application/json, application/msgpack;q=0", MIME_JSON},
+	}
+	for _, c := range cases {
+		request := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+		request.Header.Set("Accept", c.accept)
+		ctx, _ := newTestContext(newTestApplication(t), request)
+
+		contentType, _, err := ctx.negotiate(map[string]string{"id": "1"})
+		assert.NoError(t, err, c.accept)
+		assert.Equal(t, c.contentType, contentType, c.accept)
+		assert.Equal(t, "Accept", ctx.Response.Header().Get("Vary"))
+	}
+}
+
+func TestNegotiateNotAcceptable(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+	request.Header.Set("Accept", "text/html, application/json;q=0")
+	ctx, recorder := newTestContext(newTestApplication(t), request)
+
+	err := ctx.Finish(http.StatusOK, map[string]string{"id": "1"})
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusNotAcceptable, err.(ErrHTTP).Code())
+	assert.Equal(t, CODE_NOT_ACCEPTABLE, ErrorCode(err.(ErrHTTP)))
+	assert.Equal(t, "Accept", recorder.Header().Get("Vary"))
+}
@@ -0,0 +1,275 @@
+package application
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+)
+
+const (
+	CODE_TYPE = "type"
+
+	QUERY_TAG   = "query"
+	DEFAULT_TAG = "default"
+)
+
+type routeKey struct{}
+
+var (
+	uuidType = reflect.TypeOf(uuid.UUID{})
+	timeType = reflect.TypeOf(time.Time{})
+)
+
+// Param returns path parameter of route, like id of /users/:id
+func (self *Context) Param(name string) string {
+	return httprouter.ParamsFromContext(self.Request.Context()).ByName(name)
+}
+
+// WithRoute wraps handler of route to store its pattern, like /users/:id, on context of its requests
+// so Route returns it
+func WithRoute(route string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), routeKey{}, route)))
+	})
+}
+
+// Route returns pattern of route that matched request, like /users/:id,
+// empty if its handler is not wrapped by WithRoute
+func (self *Context) Route() string {
+	route, _ := self.Request.Context().Value(routeKey{}).(string)
+	return route
+}
+
+// ParamUUID returns path parameter as uuid, ErrValidation if it is not
+func (self *Context) ParamUUID(name string) (uuid.UUID, error) {
+	id, err := uuid.Parse(self.Param(name))
+	if err != nil {
+		return uuid.Nil, NewErrValidation(
+			"path parameter "+name+" is not a uuid",
+			FieldError{Field: name, Code: CODE_UUID, Message: "must be a uuid"},
+		)
+	}
+
+	return id, nil
+}
+
+// ParamInt returns path parameter as int, ErrValidation if it is not
+func (self *Context) ParamInt(name string) (int, error) {
+	n, err := strconv.Atoi(self.Param(name))
+	if err != nil {
+		return 0, NewErrValidation(
+			"path parameter "+name+" is not an integer",
+			FieldError{Field: name, Code: CODE_TYPE, Message: "must be an integer"},
+		)
+	}
+
+	return n, nil
+}
+
+// Query returns query parameter of request url
+func (self *Context) Query(name string) string {
+	return self.Request.URL.Query().Get(name)
+}
+
+// QueryInt returns query parameter as int or def if it is not set, ErrValidation if it is not int
+func (self *Context) QueryInt(name string, def int) (int, error) {
+	value := self.Query(name)
+	if value == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, NewErrValidation(
+			"query parameter "+name+" is not an integer",
+			FieldError{Field: name, Code: CODE_TYPE, Message: "must be an integer"},
+		)
+	}
+
+	return n, nil
+}
+
+// QueryBool returns query parameter as bool or def if it is not set, ErrValidation if it is not bool
+func (self *Context) QueryBool(name string, def bool) (bool, error) {
+	value := self.Query(name)
+	if value == "" {
+		return def, nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, NewErrValidation(
+			"query parameter "+name+" is not a boolean",
+			FieldError{Field: name, Code: CODE_TYPE, Message: "must be true or false"},
+		)
+	}
+
+	return b, nil
+}
+
+// BindQuery binds query string of request to struct fields by their query tags,
+// or json tags if not set, then validates struct like DecodeModel.
+// default tag is value of fields that are not in query string, slices take repeated parameters.
+//
+//	type listQuery struct {
+//		Offset int    `query:"offset" default:"0" validate:"min=0"`
+//		Limit  int    `query:"limit" default:"20" validate:"min=1,max=100"`
+//		Sort   string `query:"sort" default:"createdAt" validate:"enum=createdAt|firstName"`
+//	}
+//
+// v that is not a pointer to struct or has a field of unsupported type is returned as error,
+// that is not ErrHTTP so it is written as internal error.
+func (self *Context) BindQuery(v interface{}) error {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return errors.Errorf("BindQuery: %T is not a pointer to struct", v)
+	}
+	value = value.Elem()
+
+	query := self.Request.URL.Query()
+	fields := []FieldError{}
+	typ := value.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name, ok := field.Tag.Lookup(QUERY_TAG)
+		if !ok {
+			name = fieldName(field)
+		}
+		if name == "-" {
+			continue
+		}
+		if !isQueryType(field.Type) {
+			return errors.New("BindQuery: type " + field.Type.String() + " of " + typ.String() + "." +
+				field.Name + " is not supported")
+		}
+
+		values, ok := query[name]
+		if !ok || len(values) == 0 {
+			def, ok := field.Tag.Lookup(DEFAULT_TAG)
+			if !ok {
+				continue
+			}
+			values = []string{def}
+			if field.Type.Kind() == reflect.Slice {
+				values = strings.Split(def, ",")
+			}
+		}
+
+		if err := setField(value.Field(i), values); err != nil {
+			fields = append(fields, FieldError{Field: name, Code: CODE_TYPE, Message: err.Error()})
+		}
+	}
+
+	if len(fields) > 0 {
+		return NewErrValidation("query string has invalid parameters", fields...)
+	}
+
+	return Validate(v)
+}
+
+// isQueryType reports whether setField can set field of type from query parameters
+func isQueryType(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Slice || typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == uuidType || typ == timeType {
+		return true
+	}
+
+	switch typ.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
+func setField(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Slice {
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(slice.Index(i), value); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	if field.Kind() == reflect.Ptr {
+		ptr := reflect.New(field.Type().Elem())
+		if err := setValue(ptr.Elem(), values[0]); err != nil {
+			return err
+		}
+		field.Set(ptr)
+		return nil
+	}
+
+	return setValue(field, values[0])
+}
+
+func setValue(field reflect.Value, value string) error {
+	switch field.Type() {
+	case uuidType:
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return errors.New("must be a uuid")
+		}
+		field.Set(reflect.ValueOf(id))
+		return nil
+	case timeType:
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return errors.New("must be a RFC 3339 time")
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("must be true or false")
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return errors.New("must be a positive integer")
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		field.SetFloat(f)
+	default:
+		return errors.New("type " + field.Type().String() + " is not supported")
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package application
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alecthomas/assert"
+)
+
+type listQuery struct {
+	Offset int      `query:"offset" default:"0" validate:"min=0"`
+	Limit  int      `query:"limit" default:"20" validate:"min=1,max=100"`
+	Tags   []string `query:"tag"`
+	Active *bool    `query:"active"`
+}
+
+func queryContext(t *testing.T, query string) *Context {
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/users?"+query, nil)
+	ctx, _ := newTestContext(newTestApplication(t), request)
+	return ctx
+}
+
+func TestBindQuery(t *testing.T) {
+	q := listQuery{}
+	assert.NoError(t, queryContext(t, "limit=5&tag=a&tag=b&active=true").BindQuery(&q))
+	assert.Equal(t, 0, q.Offset)
+	assert.Equal(t, 5, q.Limit)
+	assert.Equal(t, []string{"a", "b"}, q.Tags)
+	assert.True(t, *q.Active)
+
+	err := queryContext(t, "limit=x").BindQuery(&listQuery{})
+	_, ok := err.(ErrValidation)
+	assert.True(t, ok, "%v", err)
+}
+
+func TestBindQueryReturnsErrorOnBadTarget(t *testing.T) {
+	ctx := queryContext(t, "at=1")
+
+	for _, v := range []interface{}{listQuery{}, (*listQuery)(nil), new(int)} {
+		err := ctx.BindQuery(v)
+		assert.Error(t, err)
+		_, ok := err.(ErrHTTP)
+		assert.False(t, ok)
+	}
+
+	// unsupported field fails even if query string does not set it
+	err := queryContext(t, "").BindQuery(&struct {
+		At map[string]string `query:"at"`
+	}{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "map[string]string")
+}
@@ -0,0 +1,176 @@
+package application
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	repository "microtecture/usecase/repositories"
+)
+
+type permissionCacheEntry struct {
+	permissions []string
+	expiresAt   time.Time
+}
+
+// permissionCache caches permissions of every role for ttl, zero ttl loads them every time
+type permissionCache struct {
+	mutex   *sync.RWMutex
+	ttl     time.Duration
+	entries map[string]permissionCacheEntry
+}
+
+func newPermissionCache(ttl time.Duration) *permissionCache {
+	return &permissionCache{
+		mutex:   &sync.RWMutex{},
+		ttl:     ttl,
+		entries: make(map[string]permissionCacheEntry),
+	}
+}
+
+func (self *permissionCache) get(ctx context.Context, roles repository.RoleRepository, role string) ([]string, error) {
+	self.mutex.RLock()
+	entry, ok := self.entries[role]
+	self.mutex.RUnlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.permissions, nil
+	}
+
+	permissions, err := roles.Permissions(ctx, role)
+	if err != nil {
+		return nil, err
+	}
+	if self.ttl == 0 {
+		return permissions, nil
+	}
+
+	self.mutex.Lock()
+	self.entries[role] = permissionCacheEntry{permissions, time.Now().Add(self.ttl)}
+	self.mutex.Unlock()
+
+	return permissions, nil
+}
+
+// grants returns union of permissions of roles
+func (self *permissionCache) grants(ctx context.Context, roles repository.RoleRepository, names []string) (map[string]bool, error) {
+	granted := make(map[string]bool)
+	for _, name := range names {
+		permissions, err := self.get(ctx, roles, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, permission := range permissions {
+			granted[permission] = true
+		}
+	}
+
+	return granted, nil
+}
+
+func (self *permissionCache) invalidate(roles ...string) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if len(roles) == 0 {
+		self.entries = make(map[string]permissionCacheEntry)
+		return
+	}
+
+	for _, role := range roles {
+		delete(self.entries, role)
+	}
+}
+
+// InvalidatePermissions drops cached permissions of roles, or of every role if none given
+func (self application) InvalidatePermissions(roles ...string) {
+	self.permissions.invalidate(roles...)
+}
+
+// Policy decides whether principal of context may run action
+type Policy func(ctx *Context) (bool, error)
+
+// AnyRole permits principal that has one of roles
+func AnyRole(roles ...string) Policy {
+	return func(ctx *Context) (bool, error) {
+		if ctx.Principal == nil {
+			return false, nil
+		}
+
+		for _, role := range roles {
+			if ctx.Principal.HasRole(role) {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}
+}
+
+// AllPermissions permits principal that has every permission
+func AllPermissions(permissions ...string) Policy {
+	return func(ctx *Context) (bool, error) {
+		if ctx.Principal == nil {
+			return false, nil
+		}
+
+		for _, permission := range permissions {
+			ok, err := ctx.Principal.HasPermission(permission)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+
+		return true, nil
+	}
+}
+
+// AnyPermission permits principal that has one of permissions
+func AnyPermission(permissions ...string) Policy {
+	return func(ctx *Context) (bool, error) {
+		if ctx.Principal == nil {
+			return false, nil
+		}
+
+		for _, permission := range permissions {
+			ok, err := ctx.Principal.HasPermission(permission)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+
+		return false, nil
+	}
+}
+
+// Owner permits principal that owns the resource, owner returns user id of resource owner
+func Owner(owner func(ctx *Context) (uuid.UUID, error)) Policy {
+	return func(ctx *Context) (bool, error) {
+		if ctx.Principal == nil {
+			return false, nil
+		}
+
+		id, err := owner(ctx)
+		if err != nil {
+			return false, err
+		}
+
+		return id == ctx.Principal.Id, nil
+	}
+}
+
+// AnyPolicy permits principal that one of policies permits, like
+// AnyPolicy(Owner(f), AllPermissions("user:write")) for editing own record or by admin
+func AnyPolicy(policies ...Policy) Policy {
+	return func(ctx *Context) (bool, error) {
+		for _, policy := range policies {
+			ok, err := policy(ctx)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+
+		return false, nil
+	}
+}
@@ -0,0 +1,95 @@
+package application
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert"
+	"github.com/google/uuid"
+
+	"microtecture/infrastructure/datastore"
+)
+
+// countingRoles is role repository that counts loads of permissions
+type countingRoles struct {
+	grants map[string][]string
+	loads  *int
+}
+
+func (self countingRoles) Permissions(ctx context.Context, role string) ([]string, error) {
+	*self.loads++
+	return self.grants[role], nil
+}
+
+func authorizedRequest(t *testing.T, app application, roles ...string) *http.Request {
+	token, err := app.CreateJWT(uuid.New(), "first", "last", false, roles...)
+	assert.NoError(t, err)
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set("Authorization", "Bearer "+token)
+
+	return request
+}
+
+func TestAuthorizePolicies(t *testing.T) {
+	app := newTestApplication(t)
+	app.Roles = datastore.NewMemoryRoleRepository(map[string][]string{
+		"reader": {"user:read"},
+		"admin":  {"user:read", "user:write"},
+	})
+	ok := func(ctx *Context) error { return nil }
+
+	cases := []struct {
+		name     string
+		policies []Policy
+		roles    []string
+		err      error
+	}{
+		{"no policy", nil, nil, nil},
+		{"any role", []Policy{AnyRole("admin", "reader")}, []string{"reader"}, nil},
+		{"missing role", []Policy{AnyRole("admin")}, []string{"reader"}, ErrForbidden{}},
+		{"all permissions", []Policy{AllPermissions("user:read", "user:write")}, []string{"admin"}, nil},
+		{"not all permissions", []Policy{AllPermissions("user:read", "user:write")}, []string{"reader"}, ErrForbidden{}},
+		{"any permission", []Policy{AnyPermission("user:write", "user:read")}, []string{"reader"}, nil},
+		{"every policy", []Policy{AnyRole("reader"), AllPermissions("user:write")}, []string{"reader"}, ErrForbidden{}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx, _ := newTestContext(app, authorizedRequest(t, app, c.roles...))
+			err := app.Authorize(ok, c.policies...)(ctx)
+			if c.err == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.IsType(t, c.err, err)
+			}
+		})
+	}
+
+	ctx, _ := newTestContext(app, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.IsType(t, ErrUnauthorized{}, app.Authorize(ok)(ctx))
+}
+
+func TestPermissionCache(t *testing.T) {
+	loads := 0
+	roles := countingRoles{map[string][]string{"admin": {"user:read"}}, &loads}
+	ctx := context.Background()
+
+	cache := newPermissionCache(time.Minute)
+	for i := 0; i < 3; i++ {
+		granted, err := cache.grants(ctx, roles, []string{"admin"})
+		assert.NoError(t, err)
+		assert.True(t, granted["user:read"])
+	}
+	assert.Equal(t, 1, loads)
+
+	loads = 0
+	cache = newPermissionCache(0)
+	for i := 0; i < 3; i++ {
+		_, err := cache.grants(ctx, roles, []string{"admin"})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 3, loads)
+}
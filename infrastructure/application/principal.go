@@ -0,0 +1,73 @@
+package application
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"microtecture/domain/models"
+	repository "microtecture/usecase/repositories"
+)
+
+// Principal is authenticated user of request taken from verified jwt claims
+type Principal struct {
+	Id        uuid.UUID
+	FirstName string
+	LastName  string
+	Roles     []string
+	// Token is verified access token of principal, calls to other services carry it
+	Token string
+
+	loader      func() (*models.User, error)
+	permissions func() (map[string]bool, error)
+	granted     map[string]bool
+}
+
+// HasRole reports whether principal has role
+func (self Principal) HasRole(role string) bool {
+	for _, r := range self.Roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasPermission reports whether a role of principal grants permission
+func (self *Principal) HasPermission(permission string) (bool, error) {
+	if self.granted == nil {
+		if self.permissions == nil {
+			return false, nil
+		}
+
+		granted, err := self.permissions()
+		if err != nil {
+			return false, err
+		}
+		self.granted = granted
+	}
+
+	return self.granted[permission], nil
+}
+
+func (self application) newPrincipal(ctx context.Context, claims *Claims) *Principal {
+	id := claims.Id
+	return &Principal{
+		Id:        id,
+		FirstName: claims.FirstName,
+		LastName:  claims.LastName,
+		Roles:     claims.Roles,
+		loader: func() (*models.User, error) {
+			user, err := self.Users.FindByID(ctx, id)
+			if err == repository.ErrUserNotFound {
+				return nil, NewErrUnauthorized()
+			}
+
+			return user, err
+		},
+		permissions: func() (map[string]bool, error) {
+			return self.permissions.grants(ctx, self.Roles, claims.Roles)
+		},
+	}
+}
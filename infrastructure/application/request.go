@@ -0,0 +1,136 @@
+package application
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const (
+	TRACEPARENT_HEADER    = "traceparent"
+	FORWARDED_FOR_HEADER  = "X-Forwarded-For"
+	MAX_REQUEST_ID_LENGTH = 128
+)
+
+type requestIDKey struct{}
+
+var (
+	requestIDPattern   = regexp.MustCompile(`^[A-Za-z0-9._:/+=-]+$`)
+	traceparentPattern = regexp.MustCompile(`^[0-9a-f]{2}-([0-9a-f]{32})-[0-9a-f]{16}-[0-9a-f]{2}$`)
+)
+
+// RequestIDFromContext returns request id that RequestID middleware stored in request context
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestID takes request id from X-Request-ID header, trace id of W3C traceparent header
+// or generates one, stores it on context and echoes it in response
+func (self RestController) RequestID(f Action) Action {
+	return func(ctx *Context) error {
+		id := ctx.Request.Header.Get(REQUEST_ID_HEADER)
+		if len(id) > MAX_REQUEST_ID_LENGTH || !requestIDPattern.MatchString(id) {
+			id = ""
+		}
+
+		traceparent := strings.ToLower(strings.TrimSpace(ctx.Request.Header.Get(TRACEPARENT_HEADER)))
+		if match := traceparentPattern.FindStringSubmatch(traceparent); match != nil && id == "" {
+			id = match[1]
+		}
+
+		if id == "" {
+			id = uuid.New().String()
+		}
+
+		ctx.RequestID = id
+		ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), requestIDKey{}, id))
+		ctx.Response.Header().Set(REQUEST_ID_HEADER, id)
+
+		return f(ctx)
+	}
+}
+
+// RemoteAddr sets remote address of context from connection, or from X-Forwarded-For
+// when connection is from a trusted proxy
+func (self RestController) RemoteAddr(f Action) Action {
+	return func(ctx *Context) error {
+		ctx.RemoteAddress = self.Application.remoteAddress(ctx.Request)
+		return f(ctx)
+	}
+}
+
+func (self application) remoteAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if !self.isTrustedProxy(host) {
+		return host
+	}
+
+	forwarded := []string{}
+	for _, header := range r.Header.Values(FORWARDED_FOR_HEADER) {
+		for _, ip := range strings.Split(header, ",") {
+			forwarded = append(forwarded, strings.TrimSpace(ip))
+		}
+	}
+
+	// rightmost address is added by nearest proxy, first untrusted one is client
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		if net.ParseIP(forwarded[i]) == nil {
+			break
+		}
+		host = forwarded[i]
+		if !self.isTrustedProxy(host) {
+			break
+		}
+	}
+
+	return host
+}
+
+func (self application) isTrustedProxy(host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range self.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PropagateHeaders sets request id and trace context of span of context request
+// to header of an outbound request
+func (self *Context) PropagateHeaders(header http.Header) {
+	if self.RequestID != "" {
+		header.Set(REQUEST_ID_HEADER, self.RequestID)
+	}
+	otel.GetTextMapPropagator().Inject(self.Request.Context(), propagation.HeaderCarrier(header))
+}
+
+// NewRequest creates outbound http request that carries context of request and its correlation headers,
+// send it with client of tracing.NewHTTPClient to trace it as a child span
+func (self *Context) NewRequest(method, url string, body io.Reader) (*http.Request, error) {
+	request, err := http.NewRequestWithContext(self.Request.Context(), method, url, body)
+	if err != nil {
+		return nil, errors.New(err.Error())
+	}
+	self.PropagateHeaders(request.Header)
+
+	return request, nil
+}
@@ -0,0 +1,50 @@
+package application
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert"
+	"go.opentelemetry.io/otel"
+
+	"microtecture/infrastructure/config"
+	"microtecture/infrastructure/tracing"
+)
+
+const incomingTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestRequestIDFromTraceparent(t *testing.T) {
+	controller := NewRestController(Controller{Application: newTestApplication(t)})
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+	request.Header.Set(TRACEPARENT_HEADER, incomingTraceparent)
+
+	recorder := httptest.NewRecorder()
+	controller.Handle(func(ctx *Context) error { return nil }).ServeHTTP(recorder, request)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", recorder.Header().Get(REQUEST_ID_HEADER))
+}
+
+func TestOutboundRequestsDoNotForwardTraceparentVerbatim(t *testing.T) {
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+	provider, err := tracing.NewProvider(config.ApplicationConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, provider)
+
+	controller := NewRestController(Controller{Application: newTestApplication(t)})
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+	request.Header.Set(TRACEPARENT_HEADER, incomingTraceparent)
+
+	var outbound string
+	controller.Handle(func(ctx *Context) error {
+		r, err := ctx.NewRequest(http.MethodGet, "http://users/api/v1/users", nil)
+		outbound = r.Header.Get(TRACEPARENT_HEADER)
+		return err
+	}).ServeHTTP(httptest.NewRecorder(), request)
+
+	parts := strings.Split(outbound, "-")
+	assert.Equal(t, 4, len(parts), outbound)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", parts[1])
+	assert.NotEqual(t, "00f067aa0ba902b7", parts[2])
+	assert.Equal(t, "01", parts[3])
+}
@@ -0,0 +1,133 @@
+package application
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"microtecture/domain/models"
+	"microtecture/infrastructure/config"
+)
+
+// Tokens is issued access and refresh tokens, they are not marshaled,
+// Session writes them to response body only for header token clients
+type Tokens struct {
+	AccessToken  string `json:"-"`
+	RefreshToken string `json:"-"`
+	ExpiresIn    uint   `json:"expiresIn"`
+}
+
+// Session is response of login and refresh, tokens are set as cookies and are in body too
+// when access tokens are read from Authorization header, so clients without cookies get them.
+// refresh token is empty when refresh keeps refresh token of request.
+type Session struct {
+	AccessToken  string       `json:"accessToken,omitempty"`
+	RefreshToken string       `json:"refreshToken,omitempty"`
+	ExpiresIn    uint         `json:"expiresIn"`
+	User         *models.User `json:"user"`
+}
+
+// NewSession returns session of user with tokens, password of user is not copied
+func (self application) NewSession(user *models.User, tokens Tokens) Session {
+	u := *user
+	u.Password = nil
+
+	session := Session{ExpiresIn: tokens.ExpiresIn, User: &u}
+	for _, source := range self.Config.JWT.TokenSources {
+		if source == config.TOKEN_SOURCE_HEADER {
+			session.AccessToken = tokens.AccessToken
+			session.RefreshToken = tokens.RefreshToken
+		}
+	}
+
+	return session
+}
+
+// Login issues access token and refresh token of a new token family for user
+// and sets them as cookies of context response
+func (self application) Login(ctx *Context, user *models.User) (Tokens, error) {
+	return self.issueTokens(ctx, user, uuid.New())
+}
+
+func (self application) issueTokens(ctx *Context, user *models.User, familyID uuid.UUID) (Tokens, error) {
+	accessToken, err := self.CreateJWT(user.Id, user.FirstName, user.LastName, false, userRoles(user)...)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	refreshToken, err := self.createRefreshToken(ctx.Request.Context(), user.Id, familyID)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	tokens := Tokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    self.Config.JWT.MaxAge,
+	}
+	self.setTokenCookies(ctx, tokens)
+
+	return tokens, nil
+}
+
+// issueAccessToken issues access token of user and sets it as cookie of context response,
+// refresh token of request is kept
+func (self application) issueAccessToken(ctx *Context, user *models.User) (Tokens, error) {
+	accessToken, err := self.CreateJWT(user.Id, user.FirstName, user.LastName, false, userRoles(user)...)
+	if err != nil {
+		return Tokens{}, err
+	}
+
+	tokens := Tokens{AccessToken: accessToken, ExpiresIn: self.Config.JWT.MaxAge}
+	ctx.SetCookie(config.ACCESS_TOKEN_NAME, tokens.AccessToken, self.accessTokenCookie())
+
+	return tokens, nil
+}
+
+func (self application) accessTokenCookie() CookieOptions {
+	return CookieOptions{
+		Path:     self.Config.JWT.Path,
+		Domain:   self.Config.JWT.Domain,
+		MaxAge:   int(self.Config.JWT.MaxAge),
+		Expires:  time.Now().Add(time.Duration(self.Config.JWT.MaxAge) * time.Second),
+		Secure:   self.Config.JWT.Secure,
+		HTTPOnly: self.Config.JWT.HTTPOnly,
+		SameSite: sameSite(self.Config.JWT.SameSite),
+	}
+}
+
+func (self application) refreshTokenCookie() CookieOptions {
+	return CookieOptions{
+		Path:     self.Config.JWT.RefreshToken.Path,
+		Domain:   self.Config.JWT.RefreshToken.Domain,
+		MaxAge:   int(self.Config.JWT.RefreshToken.MaxAge),
+		Expires:  time.Now().Add(time.Duration(self.Config.JWT.RefreshToken.MaxAge) * time.Second),
+		Secure:   self.Config.JWT.RefreshToken.Secure,
+		HTTPOnly: self.Config.JWT.RefreshToken.HTTPOnly,
+		SameSite: sameSite(self.Config.JWT.RefreshToken.SameSite),
+	}
+}
+
+func (self application) setTokenCookies(ctx *Context, tokens Tokens) {
+	ctx.SetCookie(config.ACCESS_TOKEN_NAME, tokens.AccessToken, self.accessTokenCookie())
+	ctx.SetCookie(config.REFRESH_TOKEN_NAME, tokens.RefreshToken, self.refreshTokenCookie())
+}
+
+func (self application) clearTokenCookies(ctx *Context) {
+	ctx.DeleteCookie(config.ACCESS_TOKEN_NAME, self.accessTokenCookie())
+	ctx.DeleteCookie(config.REFRESH_TOKEN_NAME, self.refreshTokenCookie())
+}
+
+func sameSite(mode string) http.SameSite {
+	switch mode {
+	case config.SAME_SITE_LAX:
+		return http.SameSiteLaxMode
+	case config.SAME_SITE_STRICT:
+		return http.SameSiteStrictMode
+	case config.SAME_SITE_NONE:
+		return http.SameSiteNoneMode
+	}
+
+	return http.SameSiteDefaultMode
+}
@@ -0,0 +1,76 @@
+package application
+
+import (
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"microtecture/infrastructure/config"
+	"microtecture/infrastructure/tracing"
+)
+
+// Trace opens server span of request as child of its incoming trace context,
+// named by method and matched route, and records status code of response on it
+func (self RestController) Trace(f Action) Action {
+	return func(ctx *Context) error {
+		parent := otel.GetTextMapPropagator().Extract(
+			ctx.Request.Context(), propagation.HeaderCarrier(ctx.Request.Header),
+		)
+
+		route := ctx.Route()
+		name := ctx.Request.Method
+		if route != "" {
+			name += " " + route
+		}
+
+		spanContext, span := tracing.Tracer().Start(
+			parent,
+			name,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest(config.NAME, route, ctx.Request)...),
+			trace.WithAttributes(
+				semconv.HTTPClientIPKey.String(ctx.RemoteAddress),
+				attribute.String("http.request_id", ctx.RequestID),
+			),
+		)
+		defer span.End()
+		ctx.Request = ctx.Request.WithContext(spanContext)
+
+		err := f(ctx)
+
+		status := ctx.StatusCode()
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(status)...)
+		// client errors are not errors of server span
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+
+		return err
+	}
+}
+
+// Span returns span of request that Trace middleware opened
+func (self *Context) Span() trace.Span {
+	return trace.SpanFromContext(self.Request.Context())
+}
+
+// traceError records error that is written as internal error on span of request
+func (self *Context) traceError(err error) {
+	span := self.Span()
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// tracePrincipal sets authenticated user of request on its span
+func (self *Context) tracePrincipal(principal *Principal) {
+	self.Span().SetAttributes(
+		semconv.EnduserIDKey.String(principal.Id.String()),
+		semconv.EnduserRoleKey.String(strings.Join(principal.Roles, ",")),
+	)
+}
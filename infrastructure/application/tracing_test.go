@@ -0,0 +1,149 @@
+package application
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert"
+	"github.com/julienschmidt/httprouter"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"microtecture/infrastructure/tracing"
+)
+
+func exportSpans(t *testing.T) *tracetest.InMemoryExporter {
+	previous := otel.GetTracerProvider()
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(tracing.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	return exporter
+}
+
+func spanAttribute(span tracetest.SpanStub, key string) string {
+	for _, attribute := range span.Attributes {
+		if string(attribute.Key) == key {
+			return attribute.Value.Emit()
+		}
+	}
+
+	return ""
+}
+
+func TestTraceRestRequest(t *testing.T) {
+	exporter := exportSpans(t)
+	app := newTestApplication(t)
+	user := createTestUser(t, app)
+	token, err := app.CreateJWT(user.Id, "", "", false)
+	assert.NoError(t, err)
+
+	var received string
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(TRACEPARENT_HEADER)
+	}))
+	defer backend.Close()
+
+	controller := NewRestController(Controller{Application: app})
+	router := httprouter.New()
+	router.Handler(http.MethodGet, "/api/v1/users/:id", WithRoute("/api/v1/users/:id", controller.Handle(
+		app.Authorize(func(ctx *Context) error {
+			request, err := ctx.NewRequest(http.MethodGet, backend.URL, nil)
+			if err != nil {
+				return err
+			}
+			response, err := tracing.NewHTTPClient(time.Second).Do(request)
+			if err != nil {
+				return err
+			}
+			response.Body.Close()
+
+			return ctx.Finish(http.StatusOK, map[string]string{"id": ctx.Param("id")})
+		}),
+	)))
+
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/users/"+user.Id.String(), nil)
+	request.Header.Set(TRACEPARENT_HEADER, incomingTraceparent)
+	request.Header.Set("Authorization", "Bearer "+token)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var server, client tracetest.SpanStub
+	for _, span := range exporter.GetSpans() {
+		switch span.SpanKind {
+		case trace.SpanKindServer:
+			server = span
+		case trace.SpanKindClient:
+			client = span
+		}
+	}
+
+	assert.Equal(t, "GET /api/v1/users/:id", server.Name)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", server.Parent.SpanID().String())
+	assert.True(t, server.Parent.IsRemote())
+	assert.Equal(t, "/api/v1/users/:id", spanAttribute(server, string(semconv.HTTPRouteKey)))
+	assert.Equal(t, "200", spanAttribute(server, string(semconv.HTTPStatusCodeKey)))
+	assert.Equal(t, user.Id.String(), spanAttribute(server, string(semconv.EnduserIDKey)))
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spanAttribute(server, "http.request_id"))
+
+	// outbound request is child span of server span and carries its own trace context
+	assert.Equal(t, server.SpanContext.SpanID(), client.Parent.SpanID())
+	assert.Equal(t, server.SpanContext.TraceID(), client.SpanContext.TraceID())
+	assert.Equal(t,
+		"00-"+client.SpanContext.TraceID().String()+"-"+client.SpanContext.SpanID().String()+"-01",
+		received,
+	)
+}
+
+func TestTraceRecordsServerErrors(t *testing.T) {
+	exporter := exportSpans(t)
+	controller := NewRestController(Controller{Application: newTestApplication(t)})
+
+	recorder := httptest.NewRecorder()
+	controller.Handle(func(ctx *Context) error { return NewErrCustom(http.StatusBadGateway, "down") }).
+		ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/users", nil))
+	recorder = httptest.NewRecorder()
+	controller.Handle(func(ctx *Context) error { return NewErrNotFound("user") }).
+		ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/users", nil))
+
+	spans := exporter.GetSpans()
+	assert.Equal(t, 2, len(spans))
+	assert.Equal(t, "GET", spans[0].Name)
+	assert.Equal(t, "502", spanAttribute(spans[0], string(semconv.HTTPStatusCodeKey)))
+	assert.Equal(t, "Error", spans[0].Status.Code.String())
+	assert.Equal(t, "404", spanAttribute(spans[1], string(semconv.HTTPStatusCodeKey)))
+	assert.Equal(t, "Unset", spans[1].Status.Code.String())
+}
+
+// keptSpansExporter keeps exported spans on shutdown, in-memory exporter resets them
+type keptSpansExporter struct {
+	*tracetest.InMemoryExporter
+}
+
+func (self keptSpansExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func TestCloseExportsBufferedSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	app := newTestApplication(t)
+	app.tracer = &tracing.Provider{TracerProvider: tracing.NewTracerProvider(
+		sdktrace.WithBatcher(keptSpansExporter{exporter}, sdktrace.WithBatchTimeout(time.Hour)),
+	)}
+
+	_, span := app.tracer.Tracer(tracing.TRACER_NAME).Start(context.Background(), "buffered")
+	span.End()
+	assert.Equal(t, 0, len(exporter.GetSpans()))
+
+	assert.NoError(t, app.Close())
+	assert.Equal(t, 1, len(exporter.GetSpans()))
+	assert.Equal(t, "buffered", exporter.GetSpans()[0].Name)
+}
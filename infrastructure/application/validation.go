@@ -0,0 +1,346 @@
+package application
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	CODE_MIN     = "min"
+	CODE_MAX     = "max"
+	CODE_PATTERN = "pattern"
+	CODE_ENUM    = "enum"
+	CODE_UUID    = "uuid"
+	CODE_MOBILE  = "mobile"
+
+	RULE_MAX_BYTES = "maxbytes"
+	RULE_REGEX     = "regex"
+
+	VALIDATE_TAG = "validate"
+)
+
+// iranMobile is mobile number of Iran in 09xxxxxxxxx format
+var iranMobile = regexp.MustCompile(`^09[0-9]{9}$`)
+
+// rules caches parsed rules of fields of every struct type that is validated or registered
+var rules sync.Map
+
+// rule is a parsed rule of validate tag
+type rule struct {
+	key   string
+	param string
+	limit float64
+	re    *regexp.Regexp
+}
+
+// fieldRules is rules of an exported field of struct
+type fieldRules struct {
+	index  int
+	name   string
+	tagged bool
+	rules  []rule
+}
+
+// Validate validates fields of struct by their validate tags and returns every field error at once
+// as ErrValidation, rules are separated by comma and regex must be the last rule:
+//
+//	required      field is not zero, not empty for strings, slices and maps
+//	min=n, max=n  length of strings, slices and maps or value of numbers
+//	maxbytes=n    byte length of strings, like passwords that bcrypt limits to 72 bytes
+//	enum=a|b      string is one of values
+//	uuid          string is uuid
+//	mobile        string is Iranian mobile number like 09123456789
+//	regex=expr    string matches regular expression
+//
+// rules other than required are not checked on empty fields, nor are empty nested structs.
+// a bad tag is returned as error, RegisterValidation reports it at startup instead.
+func Validate(v interface{}) error {
+	fields, err := validateValue(reflect.ValueOf(v), "")
+	if err != nil {
+		return err
+	}
+	if len(fields) > 0 {
+		return NewErrValidation("request has invalid fields", fields...)
+	}
+
+	return nil
+}
+
+// RegisterValidation parses validate tags of models and of their nested structs,
+// call it at startup so a bad tag fails startup instead of a request
+func RegisterValidation(models ...interface{}) error {
+	for _, model := range models {
+		if err := registerType(reflect.TypeOf(model)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func registerType(typ reflect.Type) error {
+	for typ != nil && (typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil
+	}
+	if _, ok := rules.Load(typ); ok {
+		return nil
+	}
+
+	if _, err := structRules(typ); err != nil {
+		return err
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		if typ.Field(i).PkgPath == "" {
+			if err := registerType(typ.Field(i).Type); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// structRules returns rules of fields of struct type, tags of a type are parsed once
+func structRules(typ reflect.Type) ([]fieldRules, error) {
+	if cached, ok := rules.Load(typ); ok {
+		return cached.([]fieldRules), nil
+	}
+
+	fields := []fieldRules{}
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := fieldName(field)
+		if name == "-" {
+			continue
+		}
+
+		tag, tagged := field.Tag.Lookup(VALIDATE_TAG)
+		parsed, err := parseRules(tag)
+		if err != nil {
+			return nil, errors.New("validate tag of " + typ.String() + "." + field.Name + ": " + err.Error())
+		}
+		fields = append(fields, fieldRules{index: i, name: name, tagged: tagged, rules: parsed})
+	}
+
+	rules.Store(typ, fields)
+	return fields, nil
+}
+
+// parseRules parses rules of validate tag, it fails on unknown rules and bad params
+func parseRules(tag string) ([]rule, error) {
+	parsed := []rule{}
+	for tag != "" {
+		var text string
+		if strings.HasPrefix(tag, RULE_REGEX+"=") {
+			text, tag = tag, ""
+		} else if i := strings.Index(tag, ","); i >= 0 {
+			text, tag = tag[:i], tag[i+1:]
+		} else {
+			text, tag = tag, ""
+		}
+
+		r := rule{key: text}
+		if i := strings.Index(text, "="); i >= 0 {
+			r.key, r.param = text[:i], text[i+1:]
+		}
+
+		switch r.key {
+		case CODE_REQUIRED, CODE_UUID, CODE_MOBILE:
+		case CODE_MIN, CODE_MAX, RULE_MAX_BYTES:
+			limit, err := strconv.ParseFloat(r.param, 64)
+			if err != nil {
+				return nil, errors.New(r.key + " " + r.param + " is not a number")
+			}
+			r.limit = limit
+		case CODE_ENUM:
+			if r.param == "" {
+				return nil, errors.New("enum has no values")
+			}
+		case RULE_REGEX:
+			re, err := regexp.Compile(r.param)
+			if err != nil {
+				return nil, errors.New(err.Error())
+			}
+			r.re = re
+		default:
+			return nil, errors.New("rule " + r.key + " is unknown")
+		}
+		parsed = append(parsed, r)
+	}
+
+	return parsed, nil
+}
+
+func validateValue(value reflect.Value, prefix string) ([]FieldError, error) {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil, nil
+		}
+		value = value.Elem()
+	}
+
+	fields := []FieldError{}
+	switch value.Kind() {
+	case reflect.Struct:
+		structFields, err := structRules(value.Type())
+		if err != nil {
+			return nil, err
+		}
+
+		for _, field := range structFields {
+			name := field.name
+			if prefix != "" {
+				name = prefix + "." + name
+			}
+
+			if field.tagged {
+				if err := validateField(value.Field(field.index), name, field.rules); err != nil {
+					fields = append(fields, *err)
+					continue
+				}
+			}
+
+			// nested structs are validated only when given
+			if !isEmpty(value.Field(field.index)) {
+				nested, err := validateValue(value.Field(field.index), name)
+				if err != nil {
+					return nil, err
+				}
+				fields = append(fields, nested...)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			nested, err := validateValue(value.Index(i), fmt.Sprintf("%s[%d]", prefix, i))
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, nested...)
+		}
+	}
+
+	return fields, nil
+}
+
+func fieldName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return field.Name
+	}
+
+	return name
+}
+
+func validateField(value reflect.Value, name string, rules []rule) *FieldError {
+	for value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+	empty := isEmpty(value)
+
+	for _, r := range rules {
+		if r.key == CODE_REQUIRED {
+			if empty {
+				return &FieldError{Field: name, Code: CODE_REQUIRED, Message: "is required"}
+			}
+			continue
+		}
+		if empty {
+			continue
+		}
+
+		if err := checkRule(value, name, r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func checkRule(value reflect.Value, name string, r rule) *FieldError {
+	switch r.key {
+	case CODE_MIN, CODE_MAX:
+		size, unit := measure(value)
+		if r.key == CODE_MIN && size < r.limit {
+			return &FieldError{Field: name, Code: CODE_MIN, Message: "must be at least " + r.param + unit}
+		}
+		if r.key == CODE_MAX && size > r.limit {
+			return &FieldError{Field: name, Code: CODE_MAX, Message: "must be at most " + r.param + unit}
+		}
+	case RULE_MAX_BYTES:
+		if value.Kind() == reflect.String && float64(len(value.String())) > r.limit {
+			return &FieldError{Field: name, Code: CODE_MAX, Message: "must be at most " + r.param + " bytes"}
+		}
+	case CODE_ENUM:
+		s := fmt.Sprint(value.Interface())
+		for _, option := range strings.Split(r.param, "|") {
+			if s == option {
+				return nil
+			}
+		}
+		return &FieldError{
+			Field:   name,
+			Code:    CODE_ENUM,
+			Message: "must be one of " + strings.ReplaceAll(r.param, "|", ", "),
+		}
+	case CODE_UUID:
+		if _, err := uuid.Parse(fmt.Sprint(value.Interface())); err != nil {
+			return &FieldError{Field: name, Code: CODE_UUID, Message: "must be a uuid"}
+		}
+	case CODE_MOBILE:
+		if !iranMobile.MatchString(value.String()) {
+			return &FieldError{Field: name, Code: CODE_MOBILE, Message: "must be a mobile number like 09123456789"}
+		}
+	case RULE_REGEX:
+		if !r.re.MatchString(value.String()) {
+			return &FieldError{Field: name, Code: CODE_PATTERN, Message: "must match " + r.param}
+		}
+	}
+
+	return nil
+}
+
+// measure returns length of strings in characters, of slices and maps or value of numbers
+func measure(value reflect.Value) (float64, string) {
+	switch value.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(value.String())), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(value.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), ""
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), ""
+	}
+
+	return 0, ""
+}
+
+func isEmpty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.String, reflect.Slice, reflect.Map:
+		return value.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return value.IsNil()
+	}
+
+	return value.IsZero()
+}
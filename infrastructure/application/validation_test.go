@@ -0,0 +1,84 @@
+package application
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/assert"
+)
+
+type validatedAddress struct {
+	City string `json:"city" validate:"required,max=3"`
+}
+
+type validatedRequest struct {
+	Name     string            `json:"name" validate:"required,min=2,max=4"`
+	Password string            `json:"password" validate:"maxbytes=4"`
+	Kind     string            `json:"kind" validate:"enum=a|b"`
+	Code     string            `json:"code" validate:"regex=^[a-z]+$"`
+	Address  *validatedAddress `json:"address"`
+}
+
+func TestValidateFields(t *testing.T) {
+	assert.NoError(t, Validate(&validatedRequest{Name: "ali", Kind: "a", Code: "ab"}))
+
+	err := Validate(&validatedRequest{
+		Password: "ééé",
+		Kind:     "c",
+		Code:     "A1",
+		Address:  &validatedAddress{City: "Tehran"},
+	})
+	assert.IsType(t, ErrValidation{}, err)
+	assert.Equal(t, []FieldError{
+		{Field: "name", Code: CODE_REQUIRED, Message: "is required"},
+		{Field: "password", Code: CODE_MAX, Message: "must be at most 4 bytes"},
+		{Field: "kind", Code: CODE_ENUM, Message: "must be one of a, b"},
+		{Field: "code", Code: CODE_PATTERN, Message: "must match ^[a-z]+$"},
+		{Field: "address.city", Code: CODE_MAX, Message: "must be at most 3 characters"},
+	}, err.(ErrValidation).Fields())
+
+	// max counts characters, so non ascii names are not cut short
+	assert.NoError(t, Validate(&validatedRequest{Name: "ابجد"}))
+}
+
+func TestValidateBadTags(t *testing.T) {
+	cases := []interface{}{
+		&struct {
+			A string `validate:"unknown"`
+		}{},
+		&struct {
+			A string `validate:"max=ten"`
+		}{},
+		&struct {
+			A string `validate:"regex=[a-"`
+		}{},
+	}
+	for _, c := range cases {
+		assert.Error(t, RegisterValidation(c))
+
+		// a bad tag is an error of request, not a panic
+		err := Validate(c)
+		assert.Error(t, err)
+		_, ok := err.(ErrHTTP)
+		assert.False(t, ok)
+	}
+
+	assert.NoError(t, RegisterValidation(validatedRequest{}, []validatedAddress{}))
+}
+
+func TestDecodeModelBodyTooLarge(t *testing.T) {
+	app := newTestApplication(t)
+	body := `{"name":"` + strings.Repeat("a", 100) + `"}`
+	request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+	ctx, _ := newTestContext(app, request)
+	err := LimitBody(16)(func(ctx *Context) error {
+		return ctx.DecodeModel(&validatedRequest{})
+	})(ctx)
+
+	assert.IsType(t, ErrCustom{}, err)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, err.(ErrHTTP).Code())
+	assert.Equal(t, CODE_BODY_TOO_LARGE, ErrorCode(err.(ErrHTTP)))
+}
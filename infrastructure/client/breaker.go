@@ -0,0 +1,72 @@
+package client
+
+import (
+	"sync"
+	"time"
+)
+
+// breaker is circuit breaker of a service, it opens after threshold consecutive failures
+// and lets one call probe service after cooldown, success of probe closes it
+type breaker struct {
+	mutex     *sync.Mutex
+	threshold uint
+	cooldown  time.Duration
+	failures  uint
+	openedAt  time.Time
+	probing   bool
+}
+
+func newBreaker(threshold uint, cooldown time.Duration) *breaker {
+	return &breaker{
+		mutex:     &sync.Mutex{},
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// allow reports whether a call may be sent and whether it is probe of open breaker,
+// every allowed call must be recorded or released with it
+func (self *breaker) allow() (ok bool, probe bool) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if self.failures < self.threshold {
+		return true, false
+	}
+	if self.probing || time.Since(self.openedAt) < self.cooldown {
+		return false, false
+	}
+	self.probing = true
+
+	return true, true
+}
+
+// record records result of an allowed call, only probe ends probing,
+// calls that were sent before breaker opened still count
+func (self *breaker) record(probe bool, failed bool) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if probe {
+		self.probing = false
+	}
+	if !failed {
+		self.failures = 0
+		return
+	}
+
+	self.failures++
+	if self.failures >= self.threshold {
+		self.openedAt = time.Now()
+	}
+}
+
+// release forgets an allowed call that is canceled by its caller
+func (self *breaker) release(probe bool) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if probe {
+		self.probing = false
+	}
+}
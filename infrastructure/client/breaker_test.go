@@ -0,0 +1,67 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert"
+)
+
+func TestBreakerOpensAndProbes(t *testing.T) {
+	b := newBreaker(2, 20*time.Millisecond)
+
+	for i := 0; i < 2; i++ {
+		ok, probe := b.allow()
+		assert.True(t, ok)
+		assert.False(t, probe)
+		b.record(probe, true)
+	}
+	ok, _ := b.allow()
+	assert.False(t, ok)
+
+	time.Sleep(30 * time.Millisecond)
+	ok, probe := b.allow()
+	assert.True(t, ok)
+	assert.True(t, probe)
+	ok, _ = b.allow()
+	assert.False(t, ok)
+
+	// failed probe opens breaker for another cooldown
+	b.record(probe, true)
+	ok, _ = b.allow()
+	assert.False(t, ok)
+
+	time.Sleep(30 * time.Millisecond)
+	ok, probe = b.allow()
+	assert.True(t, ok)
+	b.record(probe, false)
+	ok, probe = b.allow()
+	assert.True(t, ok)
+	assert.False(t, probe)
+}
+
+func TestBreakerProbeIsOwnedByItsCall(t *testing.T) {
+	b := newBreaker(1, 20*time.Millisecond)
+
+	// a call is sent while breaker is closed and ends after probe starts
+	ok, late := b.allow()
+	assert.True(t, ok)
+	ok, probe := b.allow()
+	assert.True(t, ok)
+	b.record(probe, true)
+
+	time.Sleep(30 * time.Millisecond)
+	ok, probe = b.allow()
+	assert.True(t, ok)
+	assert.True(t, probe)
+
+	b.record(late, true)
+	ok, _ = b.allow()
+	assert.False(t, ok, "late call must not end probing")
+
+	b.release(probe)
+	time.Sleep(30 * time.Millisecond)
+	ok, probe = b.allow()
+	assert.True(t, ok)
+	assert.True(t, probe)
+}
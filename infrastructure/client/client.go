@@ -0,0 +1,319 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"mime"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"microtecture/infrastructure/application"
+	"microtecture/infrastructure/config"
+	"microtecture/infrastructure/discovery"
+	"microtecture/infrastructure/tracing"
+)
+
+const (
+	CODE_CIRCUIT_OPEN = "circuit_open"
+	CODE_NO_INSTANCE  = "no_instance"
+	CODE_UNREACHABLE  = "unreachable"
+
+	maxErrorBodySize = 1024 * 1024
+)
+
+// Client calls http api and grpc server of another service by its name in services of config.
+// calls carry token of its token source, request id and trace context of their context,
+// and errors of service are returned as ErrHTTP.
+type Client struct {
+	Name      string
+	baseURL   string
+	target    string
+	tls       bool
+	timeout   time.Duration
+	retries   uint
+	backoff   time.Duration
+	token     TokenSource
+	discovery discovery.Registry
+	next      *uint32
+	http      *http.Client
+	breaker   *breaker
+}
+
+// New creates and returns client of service name, token is nil if calls carry no token.
+// url and grpc of service that are not set in config are resolved by registry in turn.
+func New(
+	conf config.ApplicationConfig, name string, token TokenSource, registry discovery.Registry,
+) (*Client, error) {
+	service, ok := conf.Services[name]
+	if !ok {
+		return nil, errors.New("service " + name + " is not set in services of config file")
+	}
+
+	timeout := time.Duration(service.Timeout) * time.Second
+
+	return &Client{
+		Name:      name,
+		baseURL:   strings.TrimSuffix(service.URL, "/"),
+		target:    service.GRPC,
+		tls:       service.TLS,
+		timeout:   timeout,
+		retries:   service.Retries,
+		backoff:   time.Duration(service.Backoff) * time.Millisecond,
+		token:     token,
+		discovery: registry,
+		next:      new(uint32),
+		http:      tracing.NewHTTPClient(timeout),
+		breaker:   newBreaker(service.BreakerThreshold, time.Duration(service.BreakerCooldown)*time.Second),
+	}, nil
+}
+
+// NewAll creates and returns clients of every service of config by their names
+func NewAll(
+	conf config.ApplicationConfig, token TokenSource, registry discovery.Registry,
+) (map[string]*Client, error) {
+	clients := make(map[string]*Client, len(conf.Services))
+	for name := range conf.Services {
+		client, err := New(conf, name, token, registry)
+		if err != nil {
+			return nil, err
+		}
+		clients[name] = client
+	}
+
+	return clients, nil
+}
+
+// Do sends body as json to path of http api of service and decodes json response to out,
+// body and out may be nil. idempotent methods are retried when service is unreachable or unavailable,
+// errors of request itself like a service without url are not retried
+//
+//	user := models.User{}
+//	err := users.Do(ctx.Request.Context(), http.MethodGet, "/api/v1/users/"+id, nil, &user)
+func (self *Client) Do(ctx context.Context, method, path string, body, out interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return errors.New(err.Error())
+		}
+	}
+
+	retryable := func(err error) bool { return false }
+	if isIdempotent(method) {
+		retryable = func(err error) bool {
+			e, ok := err.(application.ErrHTTP)
+			if !ok {
+				return false
+			}
+			code := e.Code()
+			return code == http.StatusBadGateway || code == http.StatusServiceUnavailable ||
+				code == http.StatusGatewayTimeout || code == http.StatusTooManyRequests
+		}
+	}
+
+	return self.call(ctx, retryable, func(ctx context.Context) error {
+		return self.send(ctx, method, path, data, out)
+	})
+}
+
+func (self *Client) send(ctx context.Context, method, path string, data []byte, out interface{}) error {
+	var body io.Reader
+	if data != nil {
+		body = bytes.NewReader(data)
+	}
+
+	baseURL, err := self.resolveURL(ctx)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, baseURL+path, body)
+	if err != nil {
+		return errors.New(err.Error())
+	}
+	request.Header.Set("Accept", "application/json, "+application.PROBLEM_CONTENT_TYPE)
+	if data != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if id := application.RequestIDFromContext(ctx); id != "" {
+		request.Header.Set(application.REQUEST_ID_HEADER, id)
+	}
+	token, err := self.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		request.Header.Set(config.AUTHORZIATION_NAME, "Bearer "+token)
+	}
+
+	response, err := self.http.Do(request)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return application.NewErrCustom(
+			http.StatusBadGateway,
+			"service "+self.Name+" is unreachable: "+err.Error(),
+		).WithCode(CODE_UNREACHABLE)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusBadRequest {
+		return decodeError(response)
+	}
+
+	if out == nil || response.StatusCode == http.StatusNoContent {
+		io.Copy(ioutil.Discard, response.Body)
+		return nil
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(out); err != nil {
+		return errors.New(err.Error())
+	}
+
+	return nil
+}
+
+// call runs f until it succeeds, its error is not retryable or retries of service are done,
+// waiting backoff between attempts, circuit breaker of service fails calls fast while it is open.
+// it returns error of context once context is done
+func (self *Client) call(ctx context.Context, retryable func(error) bool, f func(context.Context) error) error {
+	var err error
+	for attempt := uint(0); attempt <= self.retries; attempt++ {
+		if attempt > 0 {
+			if err := sleep(ctx, self.backoffOf(attempt)); err != nil {
+				return err
+			}
+		}
+
+		ok, probe := self.breaker.allow()
+		if !ok {
+			return application.NewErrCustom(
+				http.StatusServiceUnavailable,
+				"service "+self.Name+" is unavailable",
+			).WithCode(CODE_CIRCUIT_OPEN)
+		}
+
+		err = f(ctx)
+		if err != nil && ctx.Err() != nil {
+			self.breaker.release(probe)
+			return ctx.Err()
+		}
+		self.breaker.record(probe, isFailure(err))
+
+		if err == nil || !retryable(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// backoffOf returns wait before attempt, it doubles every attempt and has jitter up to its half
+func (self *Client) backoffOf(attempt uint) time.Duration {
+	wait := self.backoff << (attempt - 1)
+	return wait + time.Duration(rand.Int63n(int64(wait)/2+1))
+}
+
+// resolveURL returns base url of http api of service, from config or of next instance
+// that discovery resolves, so every attempt of a call may go to another instance
+func (self *Client) resolveURL(ctx context.Context) (string, error) {
+	if self.baseURL != "" {
+		return self.baseURL, nil
+	}
+
+	instance, err := self.resolve(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	scheme := "http://"
+	if self.tls {
+		scheme = "https://"
+	}
+
+	return scheme + instance.Address(), nil
+}
+
+// resolve returns instances of service in turn
+func (self *Client) resolve(ctx context.Context) (discovery.Instance, error) {
+	if self.discovery == nil {
+		return discovery.Instance{}, errors.New("service " + self.Name + " has no url or grpc in config file")
+	}
+
+	instances, err := self.discovery.Resolve(ctx, self.Name)
+	if err == discovery.ErrNoInstance {
+		return discovery.Instance{}, application.NewErrCustom(
+			http.StatusServiceUnavailable,
+			"service "+self.Name+" has no instance",
+		).WithCode(CODE_NO_INSTANCE)
+	}
+	if err != nil {
+		return discovery.Instance{}, err
+	}
+
+	i := atomic.AddUint32(self.next, 1)
+	return instances[int(i)%len(instances)], nil
+}
+
+func (self *Client) accessToken(ctx context.Context) (string, error) {
+	if self.token == nil {
+		return "", nil
+	}
+
+	return self.token(ctx)
+}
+
+// decodeError returns ErrHTTP of problem json of response,
+// or of its status code if its body is not problem json
+func decodeError(response *http.Response) error {
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, maxErrorBodySize))
+	if err != nil {
+		return errors.New(err.Error())
+	}
+
+	problem := application.Problem{}
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if mediaType == application.PROBLEM_CONTENT_TYPE || mediaType == "application/json" {
+		json.Unmarshal(body, &problem)
+	}
+	problem.Status = response.StatusCode
+
+	return application.NewErrFromProblem(problem)
+}
+
+// isFailure reports whether error counts against circuit breaker, client errors
+// and errors that are not ErrHTTP, like a token source that fails, do not
+func isFailure(err error) bool {
+	e, ok := err.(application.ErrHTTP)
+	return ok && e.Code() >= http.StatusInternalServerError
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,242 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert"
+	"github.com/pkg/errors"
+
+	"microtecture/infrastructure/application"
+	"microtecture/infrastructure/config"
+	"microtecture/infrastructure/discovery"
+)
+
+func TestResolveTakesInstancesInTurn(t *testing.T) {
+	ctx := context.Background()
+	registry := discovery.NewMemory()
+	for _, id := range []string{"users-1", "users-2", "users-3"} {
+		assert.NoError(t, registry.Register(ctx, discovery.Instance{ID: id, Name: "users", Host: id, Port: 8080}))
+	}
+	client := &Client{Name: "users", discovery: registry, next: new(uint32)}
+
+	calls := map[string]int{}
+	for i := 0; i < 6; i++ {
+		instance, err := client.resolve(ctx)
+		assert.NoError(t, err)
+		calls[instance.ID]++
+	}
+	assert.Equal(t, map[string]int{"users-1": 2, "users-2": 2, "users-3": 2}, calls)
+
+	url, err := client.resolveURL(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://users-2:8080", url)
+}
+
+func TestResolveWithoutInstance(t *testing.T) {
+	client := &Client{Name: "users", discovery: discovery.NewMemory(), next: new(uint32)}
+
+	_, err := client.resolve(context.Background())
+	e, ok := err.(application.ErrHTTP)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusServiceUnavailable, e.Code())
+	assert.Equal(t, CODE_NO_INSTANCE, application.ErrorCode(e))
+}
+
+// newTestClient returns client of http api at url that retries calls after backoff
+func newTestClient(url string, retries uint, backoff time.Duration) *Client {
+	return &Client{
+		Name:    "users",
+		baseURL: url,
+		timeout: time.Second,
+		retries: retries,
+		backoff: backoff,
+		next:    new(uint32),
+		http:    &http.Client{Timeout: time.Second},
+		breaker: newBreaker(100, time.Second),
+	}
+}
+
+// contextWithRequestID returns context that RequestID middleware stores for request id
+func contextWithRequestID(t *testing.T, id string) context.Context {
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	request.Header.Set(application.REQUEST_ID_HEADER, id)
+	ctx := application.NewContext().WithRequest(request).WithResponseWriter(httptest.NewRecorder())
+
+	var requestCtx context.Context
+	err := application.RestController{}.RequestID(func(ctx *application.Context) error {
+		requestCtx = ctx.Request.Context()
+		return nil
+	})(ctx)
+	assert.NoError(t, err)
+
+	return requestCtx
+}
+
+func TestDo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/users", r.URL.Path)
+		assert.Equal(t, "Bearer token-1", r.Header.Get(config.AUTHORZIATION_NAME))
+		assert.Equal(t, "request-1", r.Header.Get(application.REQUEST_ID_HEADER))
+
+		switch r.Method {
+		case http.MethodPost:
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			body := map[string]string{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]string{"name": body["name"] + "-created"})
+		case http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL, 0, 0)
+	client.token = func(ctx context.Context) (string, error) { return "token-1", nil }
+	ctx := contextWithRequestID(t, "request-1")
+
+	out := map[string]string{}
+	assert.NoError(t, client.Do(ctx, http.MethodPost, "/api/v1/users", map[string]string{"name": "user"}, &out))
+	assert.Equal(t, map[string]string{"name": "user-created"}, out)
+	assert.NoError(t, client.Do(ctx, http.MethodDelete, "/api/v1/users", nil, &out))
+
+	// errors of token source fail call before it is sent
+	client.token = func(ctx context.Context) (string, error) { return "", errors.New("no token") }
+	assert.EqualError(t, client.Do(ctx, http.MethodGet, "/api/v1/users", nil, nil), "no token")
+}
+
+func TestDoRetriesIdempotentMethods(t *testing.T) {
+	calls := int32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	backoff := 20 * time.Millisecond
+	client := newTestClient(server.URL, 2, backoff)
+	beginTime := time.Now()
+	assert.NoError(t, client.Do(context.Background(), http.MethodGet, "/", nil, nil))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	// backoff doubles, so calls wait backoff and twice backoff at least
+	assert.True(t, time.Since(beginTime) >= 3*backoff)
+
+	// other methods are sent once
+	atomic.StoreInt32(&calls, 0)
+	err := client.Do(context.Background(), http.MethodPost, "/", nil, nil)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, http.StatusServiceUnavailable, err.(application.ErrHTTP).Code())
+
+}
+
+func TestDoDoesNotRetryClientErrors(t *testing.T) {
+	calls := int32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL, 2, time.Millisecond)
+	_, ok := client.Do(context.Background(), http.MethodGet, "/", nil, nil).(application.ErrValidation)
+	assert.True(t, ok)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestDoRetriesUnreachableService(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	client := newTestClient(url, 1, time.Millisecond)
+	err := client.Do(context.Background(), http.MethodGet, "/", nil, nil)
+	e, ok := err.(application.ErrHTTP)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusBadGateway, e.Code())
+	assert.Equal(t, CODE_UNREACHABLE, application.ErrorCode(e))
+}
+
+func TestDoDoesNotRetryErrorsOfRequest(t *testing.T) {
+	// service without url and discovery is not retried
+	client := newTestClient("", 3, time.Second)
+	beginTime := time.Now()
+	err := client.Do(context.Background(), http.MethodGet, "/", nil, nil)
+	assert.EqualError(t, err, "service users has no url or grpc in config file")
+	assert.True(t, time.Since(beginTime) < time.Second)
+
+	ok, _ := client.breaker.allow()
+	assert.True(t, ok)
+	assert.Equal(t, uint(0), client.breaker.failures)
+}
+
+func TestDoReturnsErrorOfContextDuringBackoff(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL, 3, time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	assert.Equal(t, context.DeadlineExceeded, client.Do(ctx, http.MethodGet, "/", nil, nil))
+}
+
+func TestDecodeError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/invalid":
+			w.Header().Set("Content-Type", application.PROBLEM_CONTENT_TYPE)
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(application.Problem{
+				Status: http.StatusBadRequest,
+				Detail: "request has invalid fields",
+				Code:   application.CODE_VALIDATION,
+				Errors: []application.FieldError{{Field: "email", Code: application.CODE_REQUIRED}},
+			})
+		case "/missing":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"detail":"user not found","code":"user_not_found"}`))
+		case "/conflict":
+			w.Header().Set("Content-Type", application.PROBLEM_CONTENT_TYPE)
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"detail":"email is taken","code":"conflict"}`))
+		default:
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusTeapot)
+			w.Write([]byte(`{"detail":"is not problem json"}`))
+		}
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL, 0, 0)
+	ctx := context.Background()
+
+	validation, ok := client.Do(ctx, http.MethodPost, "/invalid", nil, nil).(application.ErrValidation)
+	assert.True(t, ok)
+	assert.Equal(t, "request has invalid fields", validation.Error())
+	assert.Equal(t, []application.FieldError{{Field: "email", Code: application.CODE_REQUIRED}}, validation.Fields())
+
+	notFound, ok := client.Do(ctx, http.MethodGet, "/missing", nil, nil).(application.ErrNotFound)
+	assert.True(t, ok)
+	assert.Equal(t, "user not found", notFound.Error())
+	assert.Equal(t, "user_not_found", notFound.ErrorCode())
+
+	conflict, ok := client.Do(ctx, http.MethodPost, "/conflict", nil, nil).(application.ErrConflict)
+	assert.True(t, ok)
+	assert.Equal(t, "email is taken", conflict.Error())
+
+	err := client.Do(ctx, http.MethodGet, "/other", nil, nil).(application.ErrHTTP)
+	assert.Equal(t, http.StatusTeapot, err.Code())
+	assert.Equal(t, http.StatusText(http.StatusTeapot), err.Error())
+}
@@ -0,0 +1,171 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+
+	"microtecture/infrastructure/application"
+)
+
+// Dial connects to grpc server of service, or of an instance that discovery resolves,
+// calls of connection carry token, request id and trace context as metadata,
+// unavailable calls are retried and errors are returned as ErrHTTP
+//
+//	conn, err := users.Dial(ctx)
+//	defer conn.Close()
+//	reply, err := pb.NewUsersClient(conn).Get(ctx, &pb.GetRequest{Id: id})
+func (self *Client) Dial(ctx context.Context, options ...grpc.DialOption) (*grpc.ClientConn, error) {
+	target := self.target
+	if target == "" {
+		instance, err := self.resolve(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if target = instance.GRPCAddress(); target == "" {
+			return nil, errors.New("instance " + instance.ID + " of service " + self.Name + " has no grpc")
+		}
+	}
+
+	dialOptions := []grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(self.unary),
+		grpc.WithChainStreamInterceptor(self.stream),
+	}
+	if self.tls {
+		dialOptions = append(dialOptions, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{})))
+	} else {
+		dialOptions = append(dialOptions, grpc.WithInsecure())
+	}
+
+	conn, err := grpc.DialContext(ctx, target, append(dialOptions, options...)...)
+	if err != nil {
+		return nil, errors.New(err.Error())
+	}
+
+	return conn, nil
+}
+
+// outgoing adds token, request id and trace context of call to its outgoing metadata
+func (self *Client) outgoing(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+
+	if id := application.RequestIDFromContext(ctx); id != "" {
+		md.Set(application.GRPC_REQUEST_ID_KEY, id)
+	}
+	token, err := self.accessToken(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	if token != "" {
+		md.Set(application.GRPC_AUTHORIZATION_KEY, "Bearer "+token)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(md))
+
+	return metadata.NewOutgoingContext(ctx, md), nil
+}
+
+func (self *Client) unary(
+	ctx context.Context, method string, req, reply interface{},
+	cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption,
+) error {
+	ctx, err := self.outgoing(ctx)
+	if err != nil {
+		return err
+	}
+
+	return self.call(ctx, isUnavailable, func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, self.timeout)
+		defer cancel()
+
+		if err := invoker(ctx, method, req, reply, cc, opts...); err != nil {
+			return application.NewErrFromStatus(err)
+		}
+
+		return nil
+	})
+}
+
+func (self *Client) stream(
+	ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn,
+	method string, streamer grpc.Streamer, opts ...grpc.CallOption,
+) (grpc.ClientStream, error) {
+	ctx, err := self.outgoing(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var cs grpc.ClientStream
+	err = self.call(ctx, isUnavailable, func(ctx context.Context) error {
+		var err error
+		if cs, err = streamer(ctx, desc, cc, method, opts...); err != nil {
+			return application.NewErrFromStatus(err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return clientStream{cs}, nil
+}
+
+// isUnavailable reports whether grpc call is not served, so it is safe to retry
+func isUnavailable(err error) bool {
+	e, ok := err.(application.ErrHTTP)
+	return ok && (e.Code() == http.StatusServiceUnavailable || e.Code() == http.StatusTooManyRequests)
+}
+
+// clientStream is client stream whose errors are returned as ErrHTTP
+type clientStream struct {
+	grpc.ClientStream
+}
+
+func (self clientStream) SendMsg(m interface{}) error {
+	return streamError(self.ClientStream.SendMsg(m))
+}
+
+func (self clientStream) RecvMsg(m interface{}) error {
+	return streamError(self.ClientStream.RecvMsg(m))
+}
+
+func streamError(err error) error {
+	if err == nil || err == io.EOF {
+		return err
+	}
+
+	return application.NewErrFromStatus(err)
+}
+
+// metadataCarrier is grpc metadata as carrier of trace context propagation
+type metadataCarrier metadata.MD
+
+func (self metadataCarrier) Get(key string) string {
+	values := metadata.MD(self).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+func (self metadataCarrier) Set(key, value string) {
+	metadata.MD(self).Set(key, value)
+}
+
+func (self metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(self))
+	for key := range self {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
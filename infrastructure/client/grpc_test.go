@@ -0,0 +1,148 @@
+package client
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"microtecture/infrastructure/application"
+)
+
+// testHealthServer fails calls of service flaky as unavailable until it is called thrice,
+// calls of service missing as not found, and records metadata of calls
+type testHealthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+	mutex *sync.Mutex
+	calls map[string]int
+	md    []metadata.MD
+}
+
+func (self testHealthServer) answer(ctx context.Context, service string) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	self.md[0] = md
+	self.calls[service]++
+
+	switch {
+	case service == "flaky" && self.calls[service] < 3:
+		return status.Error(codes.Unavailable, "try again")
+	case service == "missing":
+		return status.Error(codes.NotFound, "service not found")
+	}
+
+	return nil
+}
+
+func (self testHealthServer) Check(
+	ctx context.Context, req *grpc_health_v1.HealthCheckRequest,
+) (*grpc_health_v1.HealthCheckResponse, error) {
+	if err := self.answer(ctx, req.Service); err != nil {
+		return nil, err
+	}
+
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+}
+
+func (self testHealthServer) Watch(
+	req *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer,
+) error {
+	if err := self.answer(stream.Context(), req.Service); err != nil {
+		return err
+	}
+
+	return stream.Send(&grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING})
+}
+
+// dialTestServer serves health server on a buffered listener and dials it by client
+func dialTestServer(t *testing.T, client *Client) (grpc_health_v1.HealthClient, testHealthServer) {
+	health := testHealthServer{mutex: &sync.Mutex{}, calls: map[string]int{}, md: make([]metadata.MD, 1)}
+	s := grpc.NewServer()
+	grpc_health_v1.RegisterHealthServer(s, health)
+
+	listener := bufconn.Listen(1 << 20)
+	go s.Serve(listener)
+	t.Cleanup(s.Stop)
+
+	conn, err := client.Dial(context.Background(), grpc.WithContextDialer(
+		func(ctx context.Context, address string) (net.Conn, error) {
+			return listener.Dial()
+		},
+	))
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return grpc_health_v1.NewHealthClient(conn), health
+}
+
+func TestDialCarriesTokenRequestIDAndTraceContext(t *testing.T) {
+	propagator := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(propagator) })
+
+	client := newTestClient("", 0, 0)
+	client.target = "bufconn"
+	client.token = func(ctx context.Context) (string, error) { return "token-1", nil }
+	health, server := dialTestServer(t, client)
+
+	traceID, _ := trace.TraceIDFromHex("0af7651916cd43dd8448eb211c80319c")
+	spanID, _ := trace.SpanIDFromHex("b7ad6b7169203331")
+	ctx := trace.ContextWithSpanContext(contextWithRequestID(t, "request-1"), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled,
+	}))
+
+	_, err := health.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	assert.NoError(t, err)
+	md := server.md[0]
+	assert.Equal(t, []string{"Bearer token-1"}, md.Get(application.GRPC_AUTHORIZATION_KEY))
+	assert.Equal(t, []string{"request-1"}, md.Get(application.GRPC_REQUEST_ID_KEY))
+	assert.Equal(t, []string{"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"}, md.Get("traceparent"))
+
+	stream, err := health.Watch(ctx, &grpc_health_v1.HealthCheckRequest{})
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Bearer token-1"}, server.md[0].Get(application.GRPC_AUTHORIZATION_KEY))
+}
+
+func TestDialRetriesUnavailableCalls(t *testing.T) {
+	client := newTestClient("", 2, time.Millisecond)
+	client.target = "bufconn"
+	health, server := dialTestServer(t, client)
+
+	_, err := health.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "flaky"})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, server.calls["flaky"])
+
+	// other errors are returned as ErrHTTP without retry
+	_, err = health.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "missing"})
+	notFound, ok := err.(application.ErrNotFound)
+	assert.True(t, ok)
+	assert.Equal(t, "service not found", notFound.Error())
+	assert.Equal(t, 1, server.calls["missing"])
+
+	stream, err := health.Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "missing"})
+	assert.NoError(t, err)
+	_, err = stream.Recv()
+	_, ok = err.(application.ErrNotFound)
+	assert.True(t, ok)
+}
+
+func TestDialWithoutTarget(t *testing.T) {
+	_, err := newTestClient("", 0, 0).Dial(context.Background())
+	assert.EqualError(t, err, "service users has no url or grpc in config file")
+}
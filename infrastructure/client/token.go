@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"microtecture/infrastructure/application"
+	"microtecture/infrastructure/config"
+)
+
+// TokenSource returns access token that a call carries, no token is sent if it is empty
+type TokenSource func(ctx context.Context) (string, error)
+
+// tokenCreator creates access tokens, application is one
+type tokenCreator interface {
+	CreateJWT(userid uuid.UUID, firstName string, lastName string, lifetime bool, roles ...string) (string, error)
+}
+
+// CallerToken is TokenSource of access token of authenticated caller of context,
+// it is set by Authorize of rest and grpc controllers
+func CallerToken(ctx context.Context) (string, error) {
+	if principal, ok := application.PrincipalFromContext(ctx); ok {
+		return principal.Token, nil
+	}
+
+	return "", nil
+}
+
+// ServiceToken returns TokenSource of a token that identifies this service by its name
+// with roles, it is created by CreateJWT and renewed at half of maxAge
+func ServiceToken(creator tokenCreator, maxAge time.Duration, roles ...string) TokenSource {
+	id := uuid.NewSHA1(uuid.NameSpaceDNS, []byte(config.NAME))
+	mutex := &sync.Mutex{}
+	token := ""
+	renewAt := time.Time{}
+
+	return func(ctx context.Context) (string, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if token == "" || time.Now().After(renewAt) {
+			created, err := creator.CreateJWT(id, config.NAME, "", false, roles...)
+			if err != nil {
+				return "", err
+			}
+			token = created
+			renewAt = time.Now().Add(maxAge / 2)
+		}
+
+		return token, nil
+	}
+}
+
+// CallerOrService returns TokenSource of token of caller, or of service if call has no caller
+func CallerOrService(service TokenSource) TokenSource {
+	return func(ctx context.Context) (string, error) {
+		token, err := CallerToken(ctx)
+		if err != nil || token != "" {
+			return token, err
+		}
+
+		return service(ctx)
+	}
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert"
+	"github.com/google/uuid"
+
+	"microtecture/infrastructure/config"
+)
+
+// countingCreator creates numbered tokens
+type countingCreator struct {
+	created int
+	id      uuid.UUID
+	roles   []string
+}
+
+func (self *countingCreator) CreateJWT(
+	userid uuid.UUID, firstName string, lastName string, lifetime bool, roles ...string,
+) (string, error) {
+	self.created++
+	self.id = userid
+	self.roles = roles
+
+	return fmt.Sprintf("token-%d", self.created), nil
+}
+
+func TestServiceTokenIsRenewedAtHalfOfMaxAge(t *testing.T) {
+	creator := &countingCreator{}
+	token := ServiceToken(creator, 60*time.Millisecond, "service")
+	ctx := context.Background()
+
+	first, err := token(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "token-1", first)
+	assert.Equal(t, uuid.NewSHA1(uuid.NameSpaceDNS, []byte(config.NAME)), creator.id)
+	assert.Equal(t, []string{"service"}, creator.roles)
+
+	again, err := token(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, first, again)
+
+	time.Sleep(40 * time.Millisecond)
+	renewed, err := token(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "token-2", renewed)
+}
+
+func TestCallerOrServiceWithoutCaller(t *testing.T) {
+	token, err := CallerOrService(ServiceToken(&countingCreator{}, time.Minute))(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+}
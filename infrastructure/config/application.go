@@ -1,6 +1,7 @@
 package config
 
 import (
+	"net"
 	"os"
 
 	"github.com/pkg/errors"
@@ -13,21 +14,69 @@ type refreshToken struct {
 	Secure    bool   `yaml:"secure"`
 	HTTPOnly  bool   `yaml:"httponly"`
 	Path      string `yaml:"path"`
+	Domain    string `yaml:"domain"`
+	SameSite  string `yaml:"same_site"`
+	// ReuseGrace is nil if not set in config file, so 0 disables grace
+	ReuseGrace *uint `yaml:"reuse_grace"`
+}
+
+type jwtKey struct {
+	Kid        string `yaml:"kid"`
+	PrivateKey string `yaml:"private_key"`
+	PublicKey  string `yaml:"public_key"`
 }
 
 type jwt struct {
 	Secret       string       `yaml:"secret"`
 	Algorithm    string       `yaml:"algorithm"`
+	Keys         []jwtKey     `yaml:"keys"`
+	SigningKey   string       `yaml:"signing_key"`
 	MaxAge       uint         `yaml:"max_age"`
+	Secure       bool         `yaml:"secure"`
 	HTTPOnly     bool         `yaml:"httponly"`
+	Path         string       `yaml:"path"`
+	Domain       string       `yaml:"domain"`
+	SameSite     string       `yaml:"same_site"`
+	TokenSources []string     `yaml:"token_sources"`
+	QueryParam   string       `yaml:"query_param"`
 	RefreshToken refreshToken `yaml:"refresh_token"`
 }
 
+// isSymmetric reports whether jwt algorithm is hmac
+func (self jwt) isSymmetric() bool {
+	return self.Algorithm == HS256 || self.Algorithm == HS384 || self.Algorithm == HS512
+}
+
+func (self jwt) isAsymmetric() bool {
+	switch self.Algorithm {
+	case RS256, RS384, RS512, ES256, ES384, ES512, EDDSA:
+		return true
+	}
+
+	return false
+}
+
 type ApplicationConfig struct {
 	IsDevelopment bool
 	SecretKey     string `yaml:"secret_key"`
 	JWT           jwt    `yaml:"jwt"`
 	Port          int16  `yaml:"port"`
+	CORS          cors   `yaml:"cors"`
+
+	TrustedProxies []string `yaml:"trusted_proxies"`
+
+	// PermissionCacheTTL is nil if not set in config file, so 0 disables cache
+	PermissionCacheTTL *uint `yaml:"permission_cache_ttl"`
+
+	Tracing tracing `yaml:"tracing"`
+	Metrics metrics `yaml:"metrics"`
+	Health  health  `yaml:"health"`
+	GRPC    grpc    `yaml:"grpc"`
+	NATS    nats    `yaml:"nats"`
+	Outbox  outbox  `yaml:"outbox"`
+
+	Discovery discovery          `yaml:"discovery"`
+	Services  map[string]service `yaml:"services"`
 }
 
 func (self *ApplicationConfig) Init() error {
@@ -43,20 +92,67 @@ func (self *ApplicationConfig) Init() error {
 		return errors.New("secret_key is not set in config file or lesser than 32.")
 	}
 
-	if len(self.JWT.Secret) < 8 {
+	if !self.JWT.isSymmetric() && !self.JWT.isAsymmetric() {
+		return errors.New(
+			"jwt.algorithm is not set in config file or not in " +
+				"(HS256, HS384, HS512, RS256, RS384, RS512, ES256, ES384, ES512, EdDSA).",
+		)
+	}
+
+	if self.JWT.isSymmetric() && len(self.JWT.Secret) < 8 {
 		return errors.New("jwt.secret is not set in config file or lesser than 8.")
 	}
 
-	if self.JWT.Algorithm != HS256 &&
-		self.JWT.Algorithm != HS384 &&
-		self.JWT.Algorithm != HS512 {
-		return errors.New("jwt.algorithm is not set in config file or not in (HS256, HS384, HS512).")
+	if self.JWT.isAsymmetric() {
+		if len(self.JWT.Keys) == 0 {
+			return errors.New("jwt.keys is not set in config file.")
+		}
+
+		kids := make(map[string]bool)
+		signable := false
+		for _, key := range self.JWT.Keys {
+			if key.Kid == "" {
+				return errors.New("jwt.keys.kid is not set in config file.")
+			}
+			if kids[key.Kid] {
+				return errors.New("jwt.keys.kid " + key.Kid + " is duplicated in config file.")
+			}
+			kids[key.Kid] = true
+
+			if key.PrivateKey == "" && key.PublicKey == "" {
+				return errors.New("jwt.keys.private_key or jwt.keys.public_key of " + key.Kid + " is not set in config file.")
+			}
+			if key.Kid == self.JWT.SigningKey && key.PrivateKey != "" {
+				signable = true
+			}
+		}
+
+		if !signable {
+			return errors.New("jwt.signing_key is not set in config file or has not private_key in jwt.keys.")
+		}
 	}
 
 	if self.JWT.MaxAge == 0 {
 		return errors.New("jwt.max_age is not set in config file.")
 	}
 
+	if self.JWT.Path == "" {
+		self.JWT.Path = "/"
+	}
+
+	if len(self.JWT.TokenSources) == 0 {
+		self.JWT.TokenSources = []string{TOKEN_SOURCE_COOKIE, TOKEN_SOURCE_HEADER}
+	}
+	for _, source := range self.JWT.TokenSources {
+		if source != TOKEN_SOURCE_COOKIE && source != TOKEN_SOURCE_HEADER && source != TOKEN_SOURCE_QUERY {
+			return errors.New("jwt.token_sources is not in (cookie, header, query).")
+		}
+	}
+
+	if self.JWT.QueryParam == "" {
+		self.JWT.QueryParam = ACCESS_TOKEN_NAME
+	}
+
 	if len(self.JWT.RefreshToken.Secret) < 8 {
 		return errors.New("jwt.refresh_token.secret is not set in config file or lesser than 8.")
 
@@ -78,9 +174,98 @@ func (self *ApplicationConfig) Init() error {
 		return errors.New("jwt.refresh_token.path is not set in config file.")
 	}
 
+	if self.JWT.RefreshToken.ReuseGrace == nil {
+		grace := uint(10)
+		self.JWT.RefreshToken.ReuseGrace = &grace
+	}
+
+	if err := checkSameSite("jwt", self.JWT.SameSite, self.JWT.Secure); err != nil {
+		return err
+	}
+
+	if err := checkSameSite(
+		"jwt.refresh_token", self.JWT.RefreshToken.SameSite, self.JWT.RefreshToken.Secure,
+	); err != nil {
+		return err
+	}
+
+	self.CORS.override(os.Getenv(ENVIRONMENT_NAME))
+	// browsers send correlation and token headers of cross origin requests only if allowed
+	if self.CORS.AllowedHeaders == nil {
+		self.CORS.AllowedHeaders = []string{
+			"Origin", "Content-Length", "Content-Type", "X-Requested-With", AUTHORZIATION_NAME,
+			"X-Request-ID", "traceparent", "tracestate", REFRESH_TOKEN_NAME,
+		}
+	}
+	// browsers read request id and bearer challenge of cross origin responses only if exposed
+	if self.CORS.ExposedHeaders == nil {
+		self.CORS.ExposedHeaders = []string{"X-Request-ID", "WWW-Authenticate"}
+	}
+	if err := self.CORS.check(); err != nil {
+		return err
+	}
+
+	for _, cidr := range self.TrustedProxies {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return errors.New("trusted_proxies " + cidr + " is not a CIDR like 10.0.0.0/8.")
+		}
+	}
+
+	if self.PermissionCacheTTL == nil {
+		ttl := uint(60)
+		self.PermissionCacheTTL = &ttl
+	}
+
+	if err := self.Tracing.check(); err != nil {
+		return err
+	}
+
+	self.Health.check()
+
 	if self.Port == 0 {
 		return errors.New("http_port is not set in config file.")
 	}
 
+	if err := self.Metrics.check(self.Port); err != nil {
+		return err
+	}
+
+	if err := self.GRPC.check(self.Port, self.Metrics.Port); err != nil {
+		return err
+	}
+
+	if err := self.NATS.check(); err != nil {
+		return err
+	}
+
+	if err := self.Outbox.check(self.NATS.Enabled()); err != nil {
+		return err
+	}
+
+	if err := self.Discovery.check(); err != nil {
+		return err
+	}
+
+	for name, service := range self.Services {
+		if err := service.check(name, self.Discovery.Enabled()); err != nil {
+			return err
+		}
+		self.Services[name] = service
+	}
+
 	return nil
 }
+
+func checkSameSite(name, sameSite string, secure bool) error {
+	switch sameSite {
+	case "", SAME_SITE_LAX, SAME_SITE_STRICT:
+		return nil
+	case SAME_SITE_NONE:
+		if !secure {
+			return errors.New(name + ".same_site none needs " + name + ".secure to be true.")
+		}
+		return nil
+	}
+
+	return errors.New(name + ".same_site is not in (lax, strict, none).")
+}
@@ -13,6 +13,31 @@ const (
 	HS256 = "HS256"
 	HS384 = "HS384"
 	HS512 = "HS512"
+	RS256 = "RS256"
+	RS384 = "RS384"
+	RS512 = "RS512"
+	ES256 = "ES256"
+	ES384 = "ES384"
+	ES512 = "ES512"
+	EDDSA = "EdDSA"
+
+	SAME_SITE_LAX    = "lax"
+	SAME_SITE_STRICT = "strict"
+	SAME_SITE_NONE   = "none"
+
+	TOKEN_SOURCE_COOKIE = "cookie"
+	TOKEN_SOURCE_HEADER = "header"
+	TOKEN_SOURCE_QUERY  = "query"
+
+	TRACING_OTLP   = "otlp"
+	TRACING_STDOUT = "stdout"
+	TRACING_NONE   = "none"
+
+	DISCOVERY_STATIC = "static"
+	DISCOVERY_DNS    = "dns"
+	DISCOVERY_CONSUL = "consul"
+	DISCOVERY_MEMORY = "memory"
+	DISCOVERY_NONE   = "none"
 
 	ACCESS_TOKEN_NAME  = "access_token"
 	REFRESH_TOKEN_NAME = "refresh_token"
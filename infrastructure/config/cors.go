@@ -0,0 +1,70 @@
+package config
+
+import (
+	"github.com/pkg/errors"
+)
+
+type corsOverride struct {
+	AllowedOrigins   []string `yaml:"allowed_origins"`
+	AllowedMethods   []string `yaml:"allowed_methods"`
+	AllowedHeaders   []string `yaml:"allowed_headers"`
+	ExposedHeaders   []string `yaml:"exposed_headers"`
+	AllowCredentials *bool    `yaml:"allow_credentials"`
+	MaxAge           *int     `yaml:"max_age"`
+}
+
+type cors struct {
+	AllowedOrigins   []string                `yaml:"allowed_origins"`
+	AllowedMethods   []string                `yaml:"allowed_methods"`
+	AllowedHeaders   []string                `yaml:"allowed_headers"`
+	ExposedHeaders   []string                `yaml:"exposed_headers"`
+	AllowCredentials bool                    `yaml:"allow_credentials"`
+	MaxAge           int                     `yaml:"max_age"`
+	Environments     map[string]corsOverride `yaml:"environments"`
+}
+
+// Enabled reports whether cors is configured
+func (self cors) Enabled() bool {
+	return len(self.AllowedOrigins) > 0
+}
+
+// override replaces options that are set in environment section
+func (self *cors) override(environment string) {
+	env, ok := self.Environments[environment]
+	if !ok {
+		return
+	}
+
+	if env.AllowedOrigins != nil {
+		self.AllowedOrigins = env.AllowedOrigins
+	}
+	if env.AllowedMethods != nil {
+		self.AllowedMethods = env.AllowedMethods
+	}
+	if env.AllowedHeaders != nil {
+		self.AllowedHeaders = env.AllowedHeaders
+	}
+	if env.ExposedHeaders != nil {
+		self.ExposedHeaders = env.ExposedHeaders
+	}
+	if env.AllowCredentials != nil {
+		self.AllowCredentials = *env.AllowCredentials
+	}
+	if env.MaxAge != nil {
+		self.MaxAge = *env.MaxAge
+	}
+}
+
+func (self *cors) check() error {
+	if self.MaxAge < 0 {
+		return errors.New("cors.max_age is lesser than 0.")
+	}
+
+	for _, origin := range self.AllowedOrigins {
+		if origin == "*" && self.AllowCredentials {
+			return errors.New("cors.allowed_origins * is not allowed with cors.allow_credentials.")
+		}
+	}
+
+	return nil
+}
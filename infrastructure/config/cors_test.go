@@ -0,0 +1,112 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/assert"
+	"github.com/spf13/viper"
+)
+
+// baseTestConfig is smallest config file that Init accepts
+const baseTestConfig = `
+secret_key: 0123456789abcdef0123456789abcdef
+port: 8000
+jwt:
+  algorithm: HS256
+  secret: access-secret
+  max_age: 900
+  refresh_token:
+    secret: refresh-secret
+    algorithm: HS256
+    max_age: 86400
+    path: /
+`
+
+// initTestConfig inits application config of file content in prod environment
+func initTestConfig(t *testing.T, content string) (*ApplicationConfig, error) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(t, err)
+	path := filepath.Join(dir, CONFIG_FILE_NAME)
+	assert.NoError(t, ioutil.WriteFile(path, []byte(baseTestConfig+content), 0600))
+
+	environment, ok := os.LookupEnv(ENVIRONMENT_NAME)
+	assert.NoError(t, os.Setenv(ENVIRONMENT_NAME, "prod"))
+	viper.SetConfigFile(path)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(ENVIRONMENT_NAME, environment)
+		} else {
+			os.Unsetenv(ENVIRONMENT_NAME)
+		}
+		viper.Reset()
+		os.RemoveAll(dir)
+	})
+
+	conf := &ApplicationConfig{}
+	return conf, conf.Init()
+}
+
+func TestCORSDefaults(t *testing.T) {
+	conf, err := initTestConfig(t, `
+cors:
+  allowed_origins: [https://example.com]
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"Origin", "Content-Length", "Content-Type", "X-Requested-With", "Authorization",
+		"X-Request-ID", "traceparent", "tracestate", "refresh_token",
+	}, conf.CORS.AllowedHeaders)
+	assert.Equal(t, []string{"X-Request-ID", "WWW-Authenticate"}, conf.CORS.ExposedHeaders)
+}
+
+func TestCORSEnvironmentOverrides(t *testing.T) {
+	conf, err := initTestConfig(t, `
+cors:
+  allowed_origins: [https://example.com]
+  allowed_headers: [Content-Type]
+  allow_credentials: true
+  max_age: 600
+  environments:
+    prod:
+      allowed_origins: [https://prod.example.com]
+      allow_credentials: false
+    dev:
+      allowed_origins: [http://localhost:3000]
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://prod.example.com"}, conf.CORS.AllowedOrigins)
+	assert.False(t, conf.CORS.AllowCredentials)
+	// options that environment does not set are kept
+	assert.Equal(t, []string{"Content-Type"}, conf.CORS.AllowedHeaders)
+	assert.Equal(t, 600, conf.CORS.MaxAge)
+}
+
+func TestCORSRejectsAnyOriginWithCredentials(t *testing.T) {
+	_, err := initTestConfig(t, `
+cors:
+  allowed_origins: ["*"]
+  allow_credentials: true
+`)
+	assert.EqualError(t, err, "cors.allowed_origins * is not allowed with cors.allow_credentials.")
+
+	// environment that allows any origin must drop credentials too
+	_, err = initTestConfig(t, `
+cors:
+  allowed_origins: [https://example.com]
+  allow_credentials: true
+  environments:
+    prod:
+      allowed_origins: ["*"]
+`)
+	assert.EqualError(t, err, "cors.allowed_origins * is not allowed with cors.allow_credentials.")
+
+	conf, err := initTestConfig(t, `
+cors:
+  allowed_origins: ["*"]
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"*"}, conf.CORS.AllowedOrigins)
+}
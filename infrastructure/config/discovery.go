@@ -0,0 +1,63 @@
+package config
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type discovery struct {
+	Backend  string `yaml:"backend"`
+	File     string `yaml:"file"`
+	Domain   string `yaml:"domain"`
+	Consul   string `yaml:"consul"`
+	Token    string `yaml:"token"`
+	Host     string `yaml:"host"`
+	CacheTTL uint   `yaml:"cache_ttl"`
+	// CheckPath and CheckTLS are path and scheme of readiness check that consul calls on instance
+	CheckPath string `yaml:"check_path"`
+	CheckTLS  bool   `yaml:"check_tls"`
+}
+
+// Enabled reports whether services are registered and resolved
+func (self discovery) Enabled() bool {
+	return self.Backend != DISCOVERY_NONE
+}
+
+func (self *discovery) check() error {
+	if self.Backend == "" {
+		self.Backend = DISCOVERY_NONE
+	}
+
+	switch self.Backend {
+	case DISCOVERY_NONE, DISCOVERY_MEMORY:
+	case DISCOVERY_STATIC:
+		if self.File == "" {
+			return errors.New("discovery.file is not set in config file.")
+		}
+	case DISCOVERY_DNS:
+		if self.Domain == "" {
+			return errors.New("discovery.domain is not set in config file.")
+		}
+	case DISCOVERY_CONSUL:
+		u, err := url.Parse(self.Consul)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("discovery.consul is not like http://127.0.0.1:8500.")
+		}
+		if self.CheckPath == "" {
+			self.CheckPath = "/readyz"
+		}
+		if !strings.HasPrefix(self.CheckPath, "/") {
+			return errors.New("discovery.check_path does not start with /.")
+		}
+	default:
+		return errors.New("discovery.backend is not in (static, dns, consul, memory, none).")
+	}
+
+	if self.CacheTTL == 0 {
+		self.CacheTTL = 10
+	}
+
+	return nil
+}
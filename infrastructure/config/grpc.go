@@ -0,0 +1,52 @@
+package config
+
+import (
+	"github.com/pkg/errors"
+)
+
+type grpc struct {
+	Port           int16  `yaml:"port"`
+	CertFile       string `yaml:"cert_file"`
+	KeyFile        string `yaml:"key_file"`
+	MaxMessageSize int    `yaml:"max_message_size"`
+	// ShutdownTimeout is seconds that calls finish on shutdown before they are cancelled
+	ShutdownTimeout uint `yaml:"shutdown_timeout"`
+}
+
+// Enabled reports whether grpc server is served
+func (self grpc) Enabled() bool {
+	return self.Port != 0
+}
+
+// TLS reports whether grpc server serves over tls
+func (self grpc) TLS() bool {
+	return self.CertFile != ""
+}
+
+func (self *grpc) check(ports ...int16) error {
+	if !self.Enabled() {
+		return nil
+	}
+
+	for _, port := range ports {
+		if self.Port == port {
+			return errors.New("grpc.port is same as port of another server.")
+		}
+	}
+
+	if (self.CertFile == "") != (self.KeyFile == "") {
+		return errors.New("grpc.cert_file and grpc.key_file must be set together in config file.")
+	}
+
+	if self.MaxMessageSize < 0 {
+		return errors.New("grpc.max_message_size is lesser than 0.")
+	}
+	if self.MaxMessageSize == 0 {
+		self.MaxMessageSize = 4 * 1024 * 1024
+	}
+	if self.ShutdownTimeout == 0 {
+		self.ShutdownTimeout = 10
+	}
+
+	return nil
+}
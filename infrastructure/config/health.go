@@ -0,0 +1,16 @@
+package config
+
+type health struct {
+	CacheTTL      uint `yaml:"cache_ttl"`
+	Timeout       uint `yaml:"timeout"`
+	ShutdownDelay uint `yaml:"shutdown_delay"`
+}
+
+func (self *health) check() {
+	if self.CacheTTL == 0 {
+		self.CacheTTL = 1
+	}
+	if self.Timeout == 0 {
+		self.Timeout = 2
+	}
+}
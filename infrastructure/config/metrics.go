@@ -0,0 +1,36 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type metrics struct {
+	Port int16  `yaml:"port"`
+	Path string `yaml:"path"`
+}
+
+// Enabled reports whether metrics are served
+func (self metrics) Enabled() bool {
+	return self.Port != 0
+}
+
+func (self *metrics) check(port int16) error {
+	if !self.Enabled() {
+		return nil
+	}
+
+	if self.Port == port {
+		return errors.New("metrics.port is same as port.")
+	}
+
+	if self.Path == "" {
+		self.Path = "/metrics"
+	}
+	if !strings.HasPrefix(self.Path, "/") {
+		return errors.New("metrics.path does not begin with /.")
+	}
+
+	return nil
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type nats struct {
+	URL          string `yaml:"url"`
+	Credentials  string `yaml:"credentials"`
+	QueueGroup   string `yaml:"queue_group"`
+	DrainTimeout uint   `yaml:"drain_timeout"`
+	// AckWait is seconds that durable consumers wait for ack before they redeliver a message
+	AckWait uint `yaml:"ack_wait"`
+	// MaxDeliver is deliveries of a message to durable consumers before they drop it
+	MaxDeliver uint `yaml:"max_deliver"`
+}
+
+// Enabled reports whether nats is configured
+func (self nats) Enabled() bool {
+	return self.URL != ""
+}
+
+func (self *nats) check() error {
+	if !self.Enabled() {
+		return nil
+	}
+
+	for _, url := range strings.Split(self.URL, ",") {
+		if !strings.HasPrefix(url, "nats://") && !strings.HasPrefix(url, "tls://") {
+			return errors.New("nats.url " + url + " is not like nats://localhost:4222.")
+		}
+	}
+
+	if self.QueueGroup == "" {
+		self.QueueGroup = NAME
+	}
+
+	if self.DrainTimeout == 0 {
+		self.DrainTimeout = 30
+	}
+
+	if self.AckWait == 0 {
+		self.AckWait = 30
+	}
+	if self.MaxDeliver == 0 {
+		self.MaxDeliver = 5
+	}
+
+	return nil
+}
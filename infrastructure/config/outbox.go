@@ -0,0 +1,73 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type outbox struct {
+	// Enabled starts relay of outbox when application serves, it needs nats
+	Enabled     bool   `yaml:"enabled"`
+	Interval    uint   `yaml:"interval"`
+	BatchSize   uint   `yaml:"batch_size"`
+	MaxAttempts uint   `yaml:"max_attempts"`
+	Backoff     uint   `yaml:"backoff"`
+	MaxBackoff  uint   `yaml:"max_backoff"`
+	Retention   uint   `yaml:"retention"`
+	Subject     string `yaml:"subject"`
+	// Stream is jetstream stream that captures subject.>, relay creates it if it does not exist
+	Stream string `yaml:"stream"`
+}
+
+func (self *outbox) check(natsEnabled bool) error {
+	if self.Enabled && !natsEnabled {
+		return errors.New("outbox.enabled needs nats.url to be set in config file.")
+	}
+
+	if self.Interval == 0 {
+		self.Interval = 1
+	}
+	if self.BatchSize == 0 {
+		self.BatchSize = 100
+	}
+	if self.MaxAttempts == 0 {
+		self.MaxAttempts = 10
+	}
+	if self.Backoff == 0 {
+		self.Backoff = 1
+	}
+	if self.MaxBackoff == 0 {
+		self.MaxBackoff = 300
+	}
+	if self.MaxBackoff < self.Backoff {
+		return errors.New("outbox.max_backoff is lesser than outbox.backoff.")
+	}
+	if self.Retention == 0 {
+		self.Retention = 604800
+	}
+
+	if self.Subject == "" {
+		self.Subject = NAME
+	}
+	for _, token := range strings.Split(self.Subject, ".") {
+		if !isNatsToken(token) {
+			return errors.New("outbox.subject " + self.Subject + " is not like microtecture or org.microtecture.")
+		}
+	}
+
+	if self.Stream == "" {
+		self.Stream = strings.ToUpper(strings.ReplaceAll(self.Subject, ".", "_"))
+	}
+	if !isNatsToken(self.Stream) {
+		return errors.New("outbox.stream " + self.Stream + " is not like MICROTECTURE.")
+	}
+
+	return nil
+}
+
+// isNatsToken reports whether s is a token of nats subject, it is not empty
+// and has no dots, wildcards or whitespace
+func isNatsToken(s string) bool {
+	return s != "" && !strings.ContainsAny(s, ".*> \t\r\n")
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert"
+)
+
+func TestOutboxCheck(t *testing.T) {
+	conf := outbox{Subject: "org.microtecture"}
+	assert.NoError(t, conf.check(false))
+	assert.Equal(t, uint(300), conf.MaxBackoff)
+	assert.Equal(t, "ORG_MICROTECTURE", conf.Stream)
+
+	conf = outbox{Backoff: 60, MaxBackoff: 30}
+	assert.EqualError(t, conf.check(false), "outbox.max_backoff is lesser than outbox.backoff.")
+
+	for _, subject := range []string{"micro.*", "micro.>", ".micro", "micro.", "micro..events", "micro events"} {
+		conf = outbox{Subject: subject}
+		assert.EqualError(
+			t, conf.check(false), "outbox.subject "+subject+" is not like microtecture or org.microtecture.",
+		)
+	}
+
+	conf = outbox{Stream: "MICRO.EVENTS"}
+	assert.EqualError(t, conf.check(false), "outbox.stream MICRO.EVENTS is not like MICROTECTURE.")
+
+	// relay publishes to nats
+	conf = outbox{Enabled: true}
+	assert.EqualError(t, conf.check(false), "outbox.enabled needs nats.url to be set in config file.")
+	conf = outbox{Enabled: true}
+	assert.NoError(t, conf.check(true))
+}
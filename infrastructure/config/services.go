@@ -0,0 +1,47 @@
+package config
+
+import (
+	"net/url"
+
+	"github.com/pkg/errors"
+)
+
+type service struct {
+	URL              string `yaml:"url"`
+	GRPC             string `yaml:"grpc"`
+	TLS              bool   `yaml:"tls"`
+	Timeout          uint   `yaml:"timeout"`
+	Retries          uint   `yaml:"retries"`
+	Backoff          uint   `yaml:"backoff"`
+	BreakerThreshold uint   `yaml:"breaker_threshold"`
+	BreakerCooldown  uint   `yaml:"breaker_cooldown"`
+}
+
+// check checks service, its url and grpc are resolved by discovery if they are not set
+func (self *service) check(name string, discovery bool) error {
+	if !discovery && self.URL == "" && self.GRPC == "" {
+		return errors.New("services." + name + " has neither url nor grpc in config file.")
+	}
+
+	if self.URL != "" {
+		u, err := url.Parse(self.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("services." + name + ".url " + self.URL + " is not like http://users:8080.")
+		}
+	}
+
+	if self.Timeout == 0 {
+		self.Timeout = 10
+	}
+	if self.Backoff == 0 {
+		self.Backoff = 100
+	}
+	if self.BreakerThreshold == 0 {
+		self.BreakerThreshold = 5
+	}
+	if self.BreakerCooldown == 0 {
+		self.BreakerCooldown = 30
+	}
+
+	return nil
+}
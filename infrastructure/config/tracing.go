@@ -0,0 +1,39 @@
+package config
+
+import (
+	"github.com/pkg/errors"
+)
+
+type tracing struct {
+	Exporter    string  `yaml:"exporter"`
+	Endpoint    string  `yaml:"endpoint"`
+	Insecure    bool    `yaml:"insecure"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// Enabled reports whether spans are exported
+func (self tracing) Enabled() bool {
+	return self.Exporter == TRACING_OTLP || self.Exporter == TRACING_STDOUT
+}
+
+func (self *tracing) check() error {
+	if self.Exporter == "" {
+		self.Exporter = TRACING_NONE
+	}
+	if self.Exporter != TRACING_OTLP && self.Exporter != TRACING_STDOUT && self.Exporter != TRACING_NONE {
+		return errors.New("tracing.exporter is not in (otlp, stdout, none).")
+	}
+
+	if self.Exporter == TRACING_OTLP && self.Endpoint == "" {
+		return errors.New("tracing.endpoint is not set in config file.")
+	}
+
+	if self.SampleRatio < 0 || self.SampleRatio > 1 {
+		return errors.New("tracing.sample_ratio is not between 0 and 1.")
+	}
+	if self.SampleRatio == 0 {
+		self.SampleRatio = 1
+	}
+
+	return nil
+}
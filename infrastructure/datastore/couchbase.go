@@ -0,0 +1,122 @@
+package datastore
+
+import (
+	"context"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// operations of couchbase session and its collections take context of request, their spans are
+// children of span of context unless options set their own parent span, and context cancels them.
+// methods of gocb that have no context are still promoted from cluster and collection.
+
+// Ping pings services of cluster
+func (self couchbaseSession) Ping(ctx context.Context, opts *gocb.PingOptions) (*gocb.PingResult, error) {
+	options := gocb.PingOptions{}
+	if opts != nil {
+		options = *opts
+	}
+	options.ParentSpan, options.Context = couchbaseParent(ctx, options.ParentSpan, options.Context)
+
+	return self.Cluster.Ping(&options)
+}
+
+// Query runs N1QL query on cluster
+func (self couchbaseSession) Query(ctx context.Context, statement string, opts *gocb.QueryOptions) (*gocb.QueryResult, error) {
+	options := gocb.QueryOptions{}
+	if opts != nil {
+		options = *opts
+	}
+	options.ParentSpan, options.Context = couchbaseParent(ctx, options.ParentSpan, options.Context)
+
+	return self.Cluster.Query(statement, &options)
+}
+
+// Collection returns collection of default scope of bucket, or default collection if name is empty
+func (self couchbaseSession) Collection(bucket, name string) couchbaseCollection {
+	if name == "" {
+		return couchbaseCollection{self.Bucket(bucket).DefaultCollection()}
+	}
+
+	return couchbaseCollection{self.Bucket(bucket).Collection(name)}
+}
+
+type couchbaseCollection struct {
+	*gocb.Collection
+}
+
+// Get reads document by its id
+func (self couchbaseCollection) Get(ctx context.Context, id string, opts *gocb.GetOptions) (*gocb.GetResult, error) {
+	options := gocb.GetOptions{}
+	if opts != nil {
+		options = *opts
+	}
+	options.ParentSpan, options.Context = couchbaseParent(ctx, options.ParentSpan, options.Context)
+
+	return self.Collection.Get(id, &options)
+}
+
+// Insert creates document, it fails if document exists
+func (self couchbaseCollection) Insert(
+	ctx context.Context, id string, value interface{}, opts *gocb.InsertOptions,
+) (*gocb.MutationResult, error) {
+	options := gocb.InsertOptions{}
+	if opts != nil {
+		options = *opts
+	}
+	options.ParentSpan, options.Context = couchbaseParent(ctx, options.ParentSpan, options.Context)
+
+	return self.Collection.Insert(id, value, &options)
+}
+
+// Upsert creates or replaces document
+func (self couchbaseCollection) Upsert(
+	ctx context.Context, id string, value interface{}, opts *gocb.UpsertOptions,
+) (*gocb.MutationResult, error) {
+	options := gocb.UpsertOptions{}
+	if opts != nil {
+		options = *opts
+	}
+	options.ParentSpan, options.Context = couchbaseParent(ctx, options.ParentSpan, options.Context)
+
+	return self.Collection.Upsert(id, value, &options)
+}
+
+// Replace replaces document, it fails if document does not exist
+func (self couchbaseCollection) Replace(
+	ctx context.Context, id string, value interface{}, opts *gocb.ReplaceOptions,
+) (*gocb.MutationResult, error) {
+	options := gocb.ReplaceOptions{}
+	if opts != nil {
+		options = *opts
+	}
+	options.ParentSpan, options.Context = couchbaseParent(ctx, options.ParentSpan, options.Context)
+
+	return self.Collection.Replace(id, value, &options)
+}
+
+// Remove removes document by its id
+func (self couchbaseCollection) Remove(ctx context.Context, id string, opts *gocb.RemoveOptions) (*gocb.MutationResult, error) {
+	options := gocb.RemoveOptions{}
+	if opts != nil {
+		options = *opts
+	}
+	options.ParentSpan, options.Context = couchbaseParent(ctx, options.ParentSpan, options.Context)
+
+	return self.Collection.Remove(id, &options)
+}
+
+// couchbaseParent returns span of ctx as parent span and ctx as context of operation
+// when its options do not set them
+func couchbaseParent(
+	ctx context.Context, parent gocb.RequestSpan, opCtx context.Context,
+) (gocb.RequestSpan, context.Context) {
+	if parent == nil {
+		parent = CouchbaseSpan(ctx)
+	}
+	if opCtx == nil {
+		opCtx = ctx
+	}
+
+	return parent, opCtx
+}
@@ -64,6 +64,7 @@ func getSQLSession(driverName, url string) (*gorm.DB, error) {
 			),
 		)
 	}
+	registerTracing(session)
 
 	return session, nil
 }
@@ -118,6 +119,7 @@ func newCouchbaseSession() (*couchbaseSession, error) {
 		gocb.ClusterOptions{
 			Username: conf.Databases.Couchbase.Username,
 			Password: conf.Databases.Couchbase.Password,
+			Tracer:   newCouchbaseTracer(),
 		},
 	)
 	if err != nil {
@@ -0,0 +1,38 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/pkg/errors"
+)
+
+// PingSQL checks connection of sql session
+func (self Session) PingSQL(ctx context.Context) error {
+	if err := self.SQLSession.DB.DB().PingContext(ctx); err != nil {
+		return errors.New(err.Error())
+	}
+
+	return nil
+}
+
+// PingCouchbase pings query service of couchbase cluster, every endpoint must answer
+func (self Session) PingCouchbase(ctx context.Context) error {
+	result, err := self.CouchbaseSession.Ping(ctx, &gocb.PingOptions{
+		ServiceTypes: []gocb.ServiceType{gocb.ServiceTypeQuery},
+	})
+	if err != nil {
+		return errors.New(err.Error())
+	}
+
+	for _, reports := range result.Services {
+		for _, report := range reports {
+			if report.State != gocb.PingStateOk {
+				return errors.New(fmt.Sprintf("couchbase endpoint %s is not ok: %s", report.Remote, report.Error))
+			}
+		}
+	}
+
+	return nil
+}
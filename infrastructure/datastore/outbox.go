@@ -0,0 +1,136 @@
+package datastore
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+
+	"microtecture/domain/models"
+	repository "microtecture/usecase/repositories"
+)
+
+// outboxLockKey is key of postgres advisory lock that relay holds while it publishes
+const outboxLockKey = 0x6f7574626f78
+
+// pendingOutboxEvents selects due pending events whose aggregate has no earlier unpublished
+// event that is dead or waits for its next attempt, so events of an aggregate are published in order
+const pendingOutboxEvents = `
+SELECT * FROM outbox_events e
+WHERE e.published_at IS NULL AND e.dead_at IS NULL AND e.next_attempt_at <= now()
+AND NOT EXISTS (
+	SELECT 1 FROM outbox_events p
+	WHERE p.aggregate_type = e.aggregate_type AND p.aggregate_id = e.aggregate_id
+	AND p.published_at IS NULL AND p.sequence < e.sequence
+	AND (p.dead_at IS NOT NULL OR p.next_attempt_at > now())
+)
+ORDER BY e.sequence
+LIMIT ?`
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+// NewOutboxRepository creates and returns gorm outbox repository
+func NewOutboxRepository(session Session) repository.OutboxRepository {
+	return outboxRepository{session.SQLSession.DB}
+}
+
+func (self outboxRepository) Add(ctx context.Context, event *models.OutboxEvent) error {
+	if err := withContext(self.db, ctx).Create(event).Error; err != nil {
+		return errors.New(err.Error())
+	}
+
+	return nil
+}
+
+func (self outboxRepository) Relay(
+	ctx context.Context, limit int, f func(ctx context.Context, events []models.OutboxEvent) error,
+) (bool, error) {
+	locked := false
+	err := transactor{self.db}.Transaction(ctx, func(ctx context.Context) error {
+		// lock is released when transaction ends
+		row := withContext(self.db, ctx).Raw("SELECT pg_try_advisory_xact_lock(?)", outboxLockKey).Row()
+		if err := row.Scan(&locked); err != nil {
+			return errors.New(err.Error())
+		}
+		if !locked {
+			return nil
+		}
+
+		events := []models.OutboxEvent{}
+		if err := withContext(self.db, ctx).Raw(pendingOutboxEvents, limit).Scan(&events).Error; err != nil {
+			return errors.New(err.Error())
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		return f(ctx, events)
+	})
+
+	return locked, err
+}
+
+func (self outboxRepository) MarkPublished(ctx context.Context, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	err := withContext(self.db, ctx).Model(&models.OutboxEvent{}).
+		Where("id IN (?)", ids).
+		Update("published_at", time.Now()).Error
+	if err != nil {
+		return errors.New(err.Error())
+	}
+
+	return nil
+}
+
+func (self outboxRepository) MarkFailed(ctx context.Context, event *models.OutboxEvent) error {
+	err := withContext(self.db, ctx).Model(&models.OutboxEvent{}).
+		Where("id = ?", event.Id).
+		Updates(map[string]interface{}{
+			"attempts":        event.Attempts,
+			"last_error":      event.LastError,
+			"next_attempt_at": event.NextAttemptAt,
+			"dead_at":         event.DeadAt,
+		}).Error
+	if err != nil {
+		return errors.New(err.Error())
+	}
+
+	return nil
+}
+
+func (self outboxRepository) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	result := withContext(self.db, ctx).
+		Where("published_at IS NOT NULL AND published_at < ?", before).
+		Delete(&models.OutboxEvent{})
+	if result.Error != nil {
+		return 0, errors.New(result.Error.Error())
+	}
+
+	return result.RowsAffected, nil
+}
+
+func (self outboxRepository) Requeue(ctx context.Context, id uuid.UUID) error {
+	result := withContext(self.db, ctx).Model(&models.OutboxEvent{}).
+		Where("id = ? AND dead_at IS NOT NULL", id).
+		Updates(map[string]interface{}{
+			"attempts":        0,
+			"last_error":      "",
+			"next_attempt_at": time.Now(),
+			"dead_at":         gorm.Expr("NULL"),
+		})
+	if result.Error != nil {
+		return errors.New(result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return repository.ErrOutboxEventNotFound
+	}
+
+	return nil
+}
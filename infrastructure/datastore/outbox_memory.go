@@ -0,0 +1,145 @@
+package datastore
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"microtecture/domain/models"
+	repository "microtecture/usecase/repositories"
+)
+
+type memoryOutboxRepository struct {
+	mutex    *sync.Mutex
+	events   map[uuid.UUID]models.OutboxEvent
+	sequence *int64
+	relaying *int32
+}
+
+// NewMemoryOutboxRepository creates and returns in memory outbox repository for test goals
+func NewMemoryOutboxRepository() repository.OutboxRepository {
+	return memoryOutboxRepository{
+		mutex:    &sync.Mutex{},
+		events:   make(map[uuid.UUID]models.OutboxEvent),
+		sequence: new(int64),
+		relaying: new(int32),
+	}
+}
+
+func (self memoryOutboxRepository) Add(ctx context.Context, event *models.OutboxEvent) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	event.Sequence = atomic.AddInt64(self.sequence, 1)
+	event.CreatedAt = time.Now()
+	self.events[event.Id] = *event
+
+	return nil
+}
+
+func (self memoryOutboxRepository) Relay(
+	ctx context.Context, limit int, f func(ctx context.Context, events []models.OutboxEvent) error,
+) (bool, error) {
+	if !atomic.CompareAndSwapInt32(self.relaying, 0, 1) {
+		return false, nil
+	}
+	defer atomic.StoreInt32(self.relaying, 0)
+
+	self.mutex.Lock()
+	pending := []models.OutboxEvent{}
+	for _, event := range self.events {
+		if event.PublishedAt == nil {
+			pending = append(pending, event)
+		}
+	}
+	self.mutex.Unlock()
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Sequence < pending[j].Sequence })
+
+	now := time.Now()
+	waiting := make(map[string]bool)
+	events := []models.OutboxEvent{}
+	for _, event := range pending {
+		aggregate := event.AggregateType + "/" + event.AggregateID
+		if event.DeadAt != nil || event.NextAttemptAt.After(now) {
+			waiting[aggregate] = true
+			continue
+		}
+		if waiting[aggregate] || len(events) == limit {
+			continue
+		}
+		events = append(events, event)
+	}
+	if len(events) == 0 {
+		return true, nil
+	}
+
+	return true, f(ctx, events)
+}
+
+func (self memoryOutboxRepository) MarkPublished(ctx context.Context, ids []uuid.UUID) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	now := time.Now()
+	for _, id := range ids {
+		if event, ok := self.events[id]; ok {
+			event.PublishedAt = &now
+			self.events[id] = event
+		}
+	}
+
+	return nil
+}
+
+func (self memoryOutboxRepository) MarkFailed(ctx context.Context, event *models.OutboxEvent) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	stored, ok := self.events[event.Id]
+	if !ok {
+		return nil
+	}
+	stored.Attempts = event.Attempts
+	stored.LastError = event.LastError
+	stored.NextAttemptAt = event.NextAttemptAt
+	stored.DeadAt = event.DeadAt
+	self.events[event.Id] = stored
+
+	return nil
+}
+
+func (self memoryOutboxRepository) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	var count int64
+	for id, event := range self.events {
+		if event.PublishedAt != nil && event.PublishedAt.Before(before) {
+			delete(self.events, id)
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (self memoryOutboxRepository) Requeue(ctx context.Context, id uuid.UUID) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	event, ok := self.events[id]
+	if !ok || event.DeadAt == nil {
+		return repository.ErrOutboxEventNotFound
+	}
+	event.Attempts = 0
+	event.LastError = ""
+	event.NextAttemptAt = time.Now()
+	event.DeadAt = nil
+	self.events[id] = event
+
+	return nil
+}
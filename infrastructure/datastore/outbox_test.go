@@ -0,0 +1,64 @@
+package datastore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert"
+	"github.com/google/uuid"
+
+	"microtecture/domain/models"
+	repository "microtecture/usecase/repositories"
+)
+
+// relayedIDs returns ids of events that relay of outbox gives
+func relayedIDs(t *testing.T, outbox repository.OutboxRepository, limit int) []uuid.UUID {
+	ids := []uuid.UUID{}
+	locked, err := outbox.Relay(context.Background(), limit, func(ctx context.Context, events []models.OutboxEvent) error {
+		for _, event := range events {
+			ids = append(ids, event.Id)
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.True(t, locked)
+
+	return ids
+}
+
+func TestMemoryOutboxRepositoryHoldsEventsOfAggregateOfDeadEvent(t *testing.T) {
+	ctx := context.Background()
+	outbox := NewMemoryOutboxRepository()
+	events := []*models.OutboxEvent{}
+	for _, aggregateID := range []string{"1", "1", "2"} {
+		event, err := models.NewOutboxEvent("user", aggregateID, "user.updated", aggregateID)
+		assert.NoError(t, err)
+		assert.NoError(t, outbox.Add(ctx, event))
+		events = append(events, event)
+	}
+	first, second, other := events[0], events[1], events[2]
+
+	assert.Equal(t, []uuid.UUID{first.Id, second.Id, other.Id}, relayedIDs(t, outbox, 10))
+	assert.Equal(t, []uuid.UUID{first.Id, second.Id}, relayedIDs(t, outbox, 2))
+
+	// later events of aggregate wait for its failed event
+	first.Attempts = 1
+	first.NextAttemptAt = time.Now().Add(time.Hour)
+	assert.NoError(t, outbox.MarkFailed(ctx, first))
+	assert.Equal(t, []uuid.UUID{other.Id}, relayedIDs(t, outbox, 10))
+
+	// and for its dead event, even if it would be due, until it is requeued
+	now := time.Now()
+	first.NextAttemptAt = now
+	first.DeadAt = &now
+	assert.NoError(t, outbox.MarkFailed(ctx, first))
+	assert.Equal(t, []uuid.UUID{other.Id}, relayedIDs(t, outbox, 10))
+
+	assert.NoError(t, outbox.Requeue(ctx, first.Id))
+	assert.Equal(t, []uuid.UUID{first.Id, second.Id, other.Id}, relayedIDs(t, outbox, 10))
+
+	// published events do not hold their aggregate
+	assert.NoError(t, outbox.MarkPublished(ctx, []uuid.UUID{first.Id}))
+	assert.Equal(t, []uuid.UUID{second.Id, other.Id}, relayedIDs(t, outbox, 10))
+}
@@ -0,0 +1,33 @@
+package datastore
+
+import (
+	"context"
+
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+
+	repository "microtecture/usecase/repositories"
+)
+
+type roleRepository struct {
+	db *gorm.DB
+}
+
+// NewRoleRepository creates and returns gorm role repository
+func NewRoleRepository(session Session) repository.RoleRepository {
+	return roleRepository{session.SQLSession.DB}
+}
+
+func (self roleRepository) Permissions(ctx context.Context, role string) ([]string, error) {
+	permissions := []string{}
+	err := withContext(self.db, ctx).Table("permissions").
+		Joins("JOIN roles_permissions ON roles_permissions.permission_id = permissions.id").
+		Joins("JOIN roles ON roles.id = roles_permissions.role_id").
+		Where("roles.en_name = ? AND roles.deleted_at IS NULL", role).
+		Pluck("permissions.name", &permissions).Error
+	if err != nil {
+		return nil, errors.New(err.Error())
+	}
+
+	return permissions, nil
+}
@@ -0,0 +1,24 @@
+package datastore
+
+import (
+	"context"
+
+	repository "microtecture/usecase/repositories"
+)
+
+type memoryRoleRepository struct {
+	grants map[string][]string
+}
+
+// NewMemoryRoleRepository creates and returns in memory role repository for test goals,
+// grants maps role english name to its permission names
+func NewMemoryRoleRepository(grants map[string][]string) repository.RoleRepository {
+	return memoryRoleRepository{grants}
+}
+
+func (self memoryRoleRepository) Permissions(ctx context.Context, role string) ([]string, error) {
+	permissions := make([]string, len(self.grants[role]))
+	copy(permissions, self.grants[role])
+
+	return permissions, nil
+}
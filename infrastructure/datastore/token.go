@@ -0,0 +1,76 @@
+package datastore
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+
+	"microtecture/domain/models"
+	repository "microtecture/usecase/repositories"
+)
+
+type refreshTokenRepository struct {
+	db *gorm.DB
+}
+
+// NewRefreshTokenRepository creates and returns gorm refresh token repository
+func NewRefreshTokenRepository(session Session) repository.RefreshTokenRepository {
+	return refreshTokenRepository{session.SQLSession.DB}
+}
+
+func (self refreshTokenRepository) Create(ctx context.Context, token *models.RefreshToken) error {
+	if err := withContext(self.db, ctx).Create(token).Error; err != nil {
+		return errors.New(err.Error())
+	}
+
+	return nil
+}
+
+func (self refreshTokenRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.RefreshToken, error) {
+	token := &models.RefreshToken{}
+	err := withContext(self.db, ctx).Where("id = ?", id).First(token).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, repository.ErrRefreshTokenNotFound
+	}
+	if err != nil {
+		return nil, errors.New(err.Error())
+	}
+
+	return token, nil
+}
+
+func (self refreshTokenRepository) Use(ctx context.Context, id uuid.UUID) error {
+	result := withContext(self.db, ctx).Model(&models.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return errors.New(result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return repository.ErrRefreshTokenReused
+	}
+
+	return nil
+}
+
+func (self refreshTokenRepository) revoke(ctx context.Context, query string, args ...interface{}) error {
+	err := withContext(self.db, ctx).Model(&models.RefreshToken{}).
+		Where(query+" AND revoked_at IS NULL", args...).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return errors.New(err.Error())
+	}
+
+	return nil
+}
+
+func (self refreshTokenRepository) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	return self.revoke(ctx, "family_id = ?", familyID)
+}
+
+func (self refreshTokenRepository) RevokeUser(ctx context.Context, userID uuid.UUID) error {
+	return self.revoke(ctx, "user_id = ?", userID)
+}
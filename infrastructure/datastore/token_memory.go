@@ -0,0 +1,89 @@
+package datastore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"microtecture/domain/models"
+	repository "microtecture/usecase/repositories"
+)
+
+type memoryRefreshTokenRepository struct {
+	mutex  *sync.Mutex
+	tokens map[uuid.UUID]models.RefreshToken
+}
+
+// NewMemoryRefreshTokenRepository creates and returns in memory refresh token repository for test goals
+func NewMemoryRefreshTokenRepository() repository.RefreshTokenRepository {
+	return memoryRefreshTokenRepository{
+		mutex:  &sync.Mutex{},
+		tokens: make(map[uuid.UUID]models.RefreshToken),
+	}
+}
+
+func (self memoryRefreshTokenRepository) Create(ctx context.Context, token *models.RefreshToken) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	token.CreatedAt = time.Now()
+	self.tokens[token.Id] = *token
+
+	return nil
+}
+
+func (self memoryRefreshTokenRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.RefreshToken, error) {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	token, ok := self.tokens[id]
+	if !ok {
+		return nil, repository.ErrRefreshTokenNotFound
+	}
+
+	return &token, nil
+}
+
+func (self memoryRefreshTokenRepository) Use(ctx context.Context, id uuid.UUID) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	token, ok := self.tokens[id]
+	if !ok {
+		return repository.ErrRefreshTokenNotFound
+	}
+	if token.UsedAt != nil {
+		return repository.ErrRefreshTokenReused
+	}
+
+	now := time.Now()
+	token.UsedAt = &now
+	self.tokens[id] = token
+
+	return nil
+}
+
+func (self memoryRefreshTokenRepository) revoke(match func(models.RefreshToken) bool) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	now := time.Now()
+	for id, token := range self.tokens {
+		if token.RevokedAt == nil && match(token) {
+			token.RevokedAt = &now
+			self.tokens[id] = token
+		}
+	}
+
+	return nil
+}
+
+func (self memoryRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	return self.revoke(func(t models.RefreshToken) bool { return t.FamilyID == familyID })
+}
+
+func (self memoryRefreshTokenRepository) RevokeUser(ctx context.Context, userID uuid.UUID) error {
+	return self.revoke(func(t models.RefreshToken) bool { return t.UserID == userID })
+}
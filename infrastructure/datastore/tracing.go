@@ -0,0 +1,159 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+	"github.com/jinzhu/gorm"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"microtecture/infrastructure/config"
+)
+
+const (
+	TRACER_NAME = config.NAME + "/infrastructure/datastore"
+
+	gormContextKey = "otel:context"
+	gormSpanKey    = "otel:span"
+)
+
+// withContext returns db whose queries are traced as child spans of span of ctx,
+// or transaction of ctx if Transaction runs it
+func withContext(db *gorm.DB, ctx context.Context) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		db = tx
+	}
+
+	return db.Set(gormContextKey, ctx)
+}
+
+// registerTracing registers gorm callbacks that open a span around every query
+func registerTracing(db *gorm.DB) {
+	callback := db.Callback()
+
+	callback.Create().Before("gorm:create").Register("otel:before_create", startSpan("INSERT"))
+	callback.Create().After("gorm:create").Register("otel:after_create", endSpan)
+	callback.Query().Before("gorm:query").Register("otel:before_query", startSpan("SELECT"))
+	callback.Query().After("gorm:query").Register("otel:after_query", endSpan)
+	callback.Update().Before("gorm:update").Register("otel:before_update", startSpan("UPDATE"))
+	callback.Update().After("gorm:update").Register("otel:after_update", endSpan)
+	callback.Delete().Before("gorm:delete").Register("otel:before_delete", startSpan("DELETE"))
+	callback.Delete().After("gorm:delete").Register("otel:after_delete", endSpan)
+	callback.RowQuery().Before("gorm:row_query").Register("otel:before_row_query", startSpan("SELECT"))
+	callback.RowQuery().After("gorm:row_query").Register("otel:after_row_query", endSpan)
+}
+
+func startSpan(operation string) func(*gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		ctx := context.Background()
+		if value, ok := scope.Get(gormContextKey); ok {
+			ctx = value.(context.Context)
+		}
+
+		table := scope.TableName()
+		_, span := otel.Tracer(TRACER_NAME).Start(
+			ctx,
+			operation+" "+table,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemPostgreSQL,
+				semconv.DBOperationKey.String(operation),
+				semconv.DBSQLTableKey.String(table),
+			),
+		)
+		scope.Set(gormSpanKey, span)
+	}
+}
+
+func endSpan(scope *gorm.Scope) {
+	value, ok := scope.Get(gormSpanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	defer span.End()
+
+	span.SetAttributes(
+		semconv.DBStatementKey.String(scope.SQL),
+		attribute.Int64("db.rows_affected", scope.DB().RowsAffected),
+	)
+
+	if err := scope.DB().Error; err != nil && !gorm.IsRecordNotFoundError(err) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// couchbaseTracer is gocb request tracer that opens opentelemetry spans for couchbase operations
+type couchbaseTracer struct {
+	tracer trace.Tracer
+}
+
+func newCouchbaseTracer() couchbaseTracer {
+	return couchbaseTracer{otel.Tracer(TRACER_NAME)}
+}
+
+func (self couchbaseTracer) RequestSpan(parent gocb.RequestSpanContext, operation string) gocb.RequestSpan {
+	ctx, ok := parent.(context.Context)
+	if !ok {
+		ctx = context.Background()
+	}
+
+	ctx, span := self.tracer.Start(
+		ctx,
+		operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemCouchbase),
+	)
+
+	return couchbaseSpan{ctx, span}
+}
+
+type couchbaseSpan struct {
+	ctx  context.Context
+	span trace.Span
+}
+
+func (self couchbaseSpan) End() {
+	self.span.End()
+}
+
+func (self couchbaseSpan) Context() gocb.RequestSpanContext {
+	return self.ctx
+}
+
+func (self couchbaseSpan) AddEvent(name string, timestamp time.Time) {
+	self.span.AddEvent(name, trace.WithTimestamp(timestamp))
+}
+
+func (self couchbaseSpan) SetAttribute(key string, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		self.span.SetAttributes(attribute.String(key, v))
+	case int:
+		self.span.SetAttributes(attribute.Int(key, v))
+	case int64:
+		self.span.SetAttributes(attribute.Int64(key, v))
+	case uint32:
+		self.span.SetAttributes(attribute.Int64(key, int64(v)))
+	case bool:
+		self.span.SetAttributes(attribute.Bool(key, v))
+	default:
+		self.span.SetAttributes(attribute.String(key, fmt.Sprint(v)))
+	}
+}
+
+// CouchbaseSpan returns span of ctx as parent span for ParentSpan of gocb operation options,
+// so couchbase operations are traced as its children. operations of couchbase session set it
+// themselves, it is for operations that they do not wrap.
+//
+//	bucket.Ping(&gocb.PingOptions{ParentSpan: datastore.CouchbaseSpan(ctx.Request.Context())})
+func CouchbaseSpan(ctx context.Context) gocb.RequestSpan {
+	return couchbaseSpan{ctx, trace.SpanFromContext(ctx)}
+}
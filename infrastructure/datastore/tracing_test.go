@@ -0,0 +1,104 @@
+package datastore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert"
+	"github.com/couchbase/gocb/v2"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"microtecture/domain/models"
+)
+
+// startTracing sets a tracer provider that exports to memory and returns exporter and a parent span
+func startTracing(t *testing.T) (*tracetest.InMemoryExporter, context.Context, trace.Span) {
+	previous := otel.GetTracerProvider()
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "parent")
+	return exporter, ctx, span
+}
+
+func childrenOf(exporter *tracetest.InMemoryExporter, parent trace.Span) map[string]tracetest.SpanStub {
+	children := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		if span.Parent.SpanID() == parent.SpanContext().SpanID() {
+			children[span.Name] = span
+		}
+	}
+
+	return children
+}
+
+func attributeOf(span tracetest.SpanStub, key string) string {
+	for _, attribute := range span.Attributes {
+		if string(attribute.Key) == key {
+			return attribute.Value.Emit()
+		}
+	}
+
+	return ""
+}
+
+func TestGormQueriesAreChildSpans(t *testing.T) {
+	exporter, ctx, parent := startTracing(t)
+
+	db, err := getSQLSession("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	defer db.Close()
+	assert.NoError(t, db.AutoMigrate(&models.User{}, &models.Group{}, &models.Role{}).Error)
+
+	users := NewUserRepository(Session{SQLSession: sqlSession{db}})
+	user := &models.User{Password: []byte("password"), MobileNumber: "09120000000"}
+	assert.NoError(t, users.Create(ctx, user))
+	_, err = users.FindByID(ctx, user.Id)
+	assert.NoError(t, err)
+	parent.End()
+
+	children := childrenOf(exporter, parent)
+	insert, ok := children["INSERT users"]
+	assert.True(t, ok, "%v", children)
+	assert.Equal(t, trace.SpanKindClient, insert.SpanKind)
+	assert.Equal(t, "users", attributeOf(insert, string(semconv.DBSQLTableKey)))
+	assert.Contains(t, attributeOf(insert, string(semconv.DBStatementKey)), "INSERT INTO \"users\"")
+
+	_, ok = children["SELECT users"]
+	assert.True(t, ok, "%v", children)
+}
+
+func TestCouchbaseOperationsAreChildSpans(t *testing.T) {
+	exporter, ctx, parent := startTracing(t)
+
+	// operations time out as nothing listens, but their spans are still ended
+	cluster, err := gocb.Connect("couchbase://127.0.0.1:1", gocb.ClusterOptions{
+		Username: "test",
+		Password: "test",
+		Tracer:   newCouchbaseTracer(),
+	})
+	assert.NoError(t, err)
+	defer cluster.Close(nil)
+	session := couchbaseSession{cluster}
+
+	_, err = session.Query(ctx, "SELECT 1", &gocb.QueryOptions{Timeout: 100 * time.Millisecond})
+	assert.Error(t, err)
+	_, err = session.Collection("test", "").Get(ctx, "id", &gocb.GetOptions{Timeout: 100 * time.Millisecond})
+	assert.Error(t, err)
+	parent.End()
+
+	children := childrenOf(exporter, parent)
+	for _, name := range []string{"query", "get"} {
+		span, ok := children[name]
+		assert.True(t, ok, "%s: %v", name, children)
+		assert.Equal(t, trace.SpanKindClient, span.SpanKind)
+		assert.Equal(t, "couchbase", attributeOf(span, string(semconv.DBSystemKey)))
+	}
+}
@@ -0,0 +1,50 @@
+package datastore
+
+import (
+	"context"
+
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+
+	repository "microtecture/usecase/repositories"
+)
+
+type txKey struct{}
+
+type transactor struct {
+	db *gorm.DB
+}
+
+// NewTransactor creates and returns gorm transactor, gorm repositories join its transactions
+func NewTransactor(session Session) repository.Transactor {
+	return transactor{session.SQLSession.DB}
+}
+
+func (self transactor) Transaction(ctx context.Context, f func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return f(ctx)
+	}
+
+	tx := withContext(self.db, ctx).BeginTx(ctx, nil)
+	if tx.Error != nil {
+		return errors.New(tx.Error.Error())
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err := f(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return errors.New(err.Error())
+	}
+
+	return nil
+}
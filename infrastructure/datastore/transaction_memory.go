@@ -0,0 +1,19 @@
+package datastore
+
+import (
+	"context"
+
+	repository "microtecture/usecase/repositories"
+)
+
+type memoryTransactor struct{}
+
+// NewMemoryTransactor creates and returns transactor of memory repositories for test goals,
+// it runs functions without isolation and does not roll back their changes
+func NewMemoryTransactor() repository.Transactor {
+	return memoryTransactor{}
+}
+
+func (self memoryTransactor) Transaction(ctx context.Context, f func(ctx context.Context) error) error {
+	return f(ctx)
+}
@@ -0,0 +1,118 @@
+package datastore
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	"microtecture/domain/models"
+	repository "microtecture/usecase/repositories"
+)
+
+const (
+	pqUniqueViolation = "23505"
+	// mobileNumberIndex is unique index that gorm names for mobile number of users
+	mobileNumberIndex = "uix_users_mobile_number"
+)
+
+type userRepository struct {
+	db *gorm.DB
+}
+
+// NewUserRepository creates and returns gorm user repository
+func NewUserRepository(session Session) repository.UserRepository {
+	return userRepository{session.SQLSession.DB}
+}
+
+func (self userRepository) Create(ctx context.Context, user *models.User) error {
+	err := withContext(self.db, ctx).Set("gorm:association_autocreate", false).Create(user).Error
+	if isDuplicateMobileNumber(err) {
+		return repository.ErrDuplicateMobileNumber
+	}
+
+	if err != nil {
+		return errors.New(err.Error())
+	}
+
+	return nil
+}
+
+func (self userRepository) find(ctx context.Context, query string, args ...interface{}) (*models.User, error) {
+	user := &models.User{}
+	err := withContext(self.db, ctx).Preload("Group").Preload("Group.Roles").Where(query, args...).First(user).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, repository.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, errors.New(err.Error())
+	}
+
+	return user, nil
+}
+
+func (self userRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	return self.find(ctx, "id = ?", id)
+}
+
+func (self userRepository) FindByMobileNumber(ctx context.Context, mobileNumber string) (*models.User, error) {
+	return self.find(ctx, "mobile_number = ?", mobileNumber)
+}
+
+func (self userRepository) Update(ctx context.Context, user *models.User) error {
+	result := withContext(self.db, ctx).Model(&models.User{Id: user.Id}).
+		Set("gorm:association_autoupdate", false).
+		Omit("Password", "Group").
+		Updates(user)
+	if isDuplicateMobileNumber(result.Error) {
+		return repository.ErrDuplicateMobileNumber
+	}
+	if result.Error != nil {
+		return errors.New(result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return repository.ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (self userRepository) SoftDelete(ctx context.Context, id uuid.UUID) error {
+	result := withContext(self.db, ctx).Where("id = ?", id).Delete(&models.User{})
+	if result.Error != nil {
+		return errors.New(result.Error.Error())
+	}
+	if result.RowsAffected == 0 {
+		return repository.ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (self userRepository) List(ctx context.Context, page repository.Page) ([]models.User, int, error) {
+	var total int
+	if err := withContext(self.db, ctx).Model(&models.User{}).Count(&total).Error; err != nil {
+		return nil, 0, errors.New(err.Error())
+	}
+
+	query := withContext(self.db, ctx).Preload("Group").Preload("Group.Roles").Order("created_at").Offset(page.Offset)
+	if page.Limit > 0 {
+		query = query.Limit(page.Limit)
+	}
+
+	users := []models.User{}
+	if err := query.Find(&users).Error; err != nil {
+		return nil, 0, errors.New(err.Error())
+	}
+
+	return users, total, nil
+}
+
+// isDuplicateMobileNumber reports whether err violates unique index of mobile number,
+// violations of other unique indexes are not duplicate mobile numbers
+func isDuplicateMobileNumber(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == pqUniqueViolation && pqErr.Constraint == mobileNumberIndex
+}
@@ -0,0 +1,168 @@
+package datastore
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"microtecture/domain/models"
+	repository "microtecture/usecase/repositories"
+)
+
+type memoryUserRepository struct {
+	mutex  *sync.RWMutex
+	users  map[uuid.UUID]models.User
+	groups map[uuid.UUID]models.Group
+}
+
+// NewMemoryUserRepository creates and returns in memory user repository for test goals,
+// users are returned with their group of groups and its roles like gorm repository preloads them
+func NewMemoryUserRepository(groups ...models.Group) repository.UserRepository {
+	repo := memoryUserRepository{
+		mutex:  &sync.RWMutex{},
+		users:  make(map[uuid.UUID]models.User),
+		groups: make(map[uuid.UUID]models.Group),
+	}
+	for _, group := range groups {
+		repo.groups[group.Id] = group
+	}
+
+	return repo
+}
+
+func (self memoryUserRepository) Create(ctx context.Context, user *models.User) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	for _, u := range self.users {
+		if u.DeletedAt == nil && u.MobileNumber == user.MobileNumber {
+			return repository.ErrDuplicateMobileNumber
+		}
+	}
+
+	hashed, err := models.HashPassword(user.Password)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	user.Id = uuid.New()
+	user.Password = hashed
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	// group is not created with user, like gorm repository does not
+	stored := *user
+	stored.Group = models.Group{}
+	self.users[user.Id] = stored
+
+	return nil
+}
+
+func (self memoryUserRepository) find(match func(models.User) bool) (*models.User, error) {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+
+	for _, u := range self.users {
+		if u.DeletedAt == nil && match(u) {
+			u = self.preload(u)
+			return &u, nil
+		}
+	}
+
+	return nil, repository.ErrUserNotFound
+}
+
+func (self memoryUserRepository) FindByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	return self.find(func(u models.User) bool { return u.Id == id })
+}
+
+func (self memoryUserRepository) FindByMobileNumber(ctx context.Context, mobileNumber string) (*models.User, error) {
+	return self.find(func(u models.User) bool { return u.MobileNumber == mobileNumber })
+}
+
+func (self memoryUserRepository) Update(ctx context.Context, user *models.User) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	stored, ok := self.users[user.Id]
+	if !ok || stored.DeletedAt != nil {
+		return repository.ErrUserNotFound
+	}
+
+	if user.MobileNumber != "" && user.MobileNumber != stored.MobileNumber {
+		for _, u := range self.users {
+			if u.DeletedAt == nil && u.MobileNumber == user.MobileNumber {
+				return repository.ErrDuplicateMobileNumber
+			}
+		}
+		stored.MobileNumber = user.MobileNumber
+	}
+	if user.FirstName != "" {
+		stored.FirstName = user.FirstName
+	}
+	if user.LastName != "" {
+		stored.LastName = user.LastName
+	}
+	if user.GroupID != uuid.Nil {
+		stored.GroupID = user.GroupID
+	}
+	stored.UpdatedAt = time.Now()
+	self.users[user.Id] = stored
+
+	return nil
+}
+
+func (self memoryUserRepository) SoftDelete(ctx context.Context, id uuid.UUID) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	stored, ok := self.users[id]
+	if !ok || stored.DeletedAt != nil {
+		return repository.ErrUserNotFound
+	}
+
+	now := time.Now()
+	stored.DeletedAt = &now
+	self.users[id] = stored
+
+	return nil
+}
+
+func (self memoryUserRepository) List(ctx context.Context, page repository.Page) ([]models.User, int, error) {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+
+	users := []models.User{}
+	for _, u := range self.users {
+		if u.DeletedAt == nil {
+			users = append(users, self.preload(u))
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].CreatedAt.Before(users[j].CreatedAt)
+	})
+
+	total := len(users)
+	if page.Offset >= total {
+		return []models.User{}, total, nil
+	}
+	users = users[page.Offset:]
+	if page.Limit > 0 && page.Limit < len(users) {
+		users = users[:page.Limit]
+	}
+
+	return users, total, nil
+}
+
+// preload sets group of user with its roles
+func (self memoryUserRepository) preload(user models.User) models.User {
+	group := self.groups[user.GroupID]
+	group.Roles = append([]models.Role{}, group.Roles...)
+	group.Users = nil
+	user.Group = group
+
+	return user
+}
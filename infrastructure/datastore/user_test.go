@@ -0,0 +1,52 @@
+package datastore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alecthomas/assert"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	"microtecture/domain/models"
+	repository "microtecture/usecase/repositories"
+)
+
+func TestIsDuplicateMobileNumber(t *testing.T) {
+	assert.True(t, isDuplicateMobileNumber(&pq.Error{Code: pqUniqueViolation, Constraint: mobileNumberIndex}))
+	assert.False(t, isDuplicateMobileNumber(&pq.Error{Code: pqUniqueViolation, Constraint: "uix_users_email"}))
+	assert.False(t, isDuplicateMobileNumber(errors.New("connection refused")))
+	assert.False(t, isDuplicateMobileNumber(nil))
+}
+
+func TestMemoryUserRepositoryPreloadsGroup(t *testing.T) {
+	group := models.Group{
+		Id:    uuid.New(),
+		Name:  "admins",
+		Roles: []models.Role{{Id: uuid.New(), EnName: "admin"}},
+	}
+	repo := NewMemoryUserRepository(group)
+	ctx := context.Background()
+
+	user := &models.User{
+		Password:     []byte("password"),
+		MobileNumber: "09120000000",
+		GroupID:      group.Id,
+	}
+	assert.NoError(t, repo.Create(ctx, user))
+
+	found, err := repo.FindByID(ctx, user.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, "admins", found.Group.Name)
+	assert.Equal(t, []models.Role{{Id: group.Roles[0].Id, EnName: "admin"}}, found.Group.Roles)
+
+	found, err = repo.FindByMobileNumber(ctx, "09120000000")
+	assert.NoError(t, err)
+	assert.Equal(t, group.Id, found.Group.Id)
+
+	users, total, err := repo.List(ctx, repository.Page{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, "admins", users[0].Group.Name)
+}
@@ -0,0 +1,167 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"microtecture/infrastructure/tracing"
+)
+
+const (
+	CONSUL_TOKEN_HEADER = "X-Consul-Token"
+
+	consulGRPCPortMeta = "grpc_port"
+)
+
+// Consul is registry of consul agent, registered instances are checked by their readiness
+// check and removed by consul if they stay unhealthy, only passing instances are resolved
+type Consul struct {
+	address     string
+	token       string
+	checkScheme string
+	checkPath   string
+	client      *http.Client
+}
+
+// NewConsul creates and returns registry of consul agent at address with acl token,
+// consul checks instances at check path over https if check tls is set
+func NewConsul(address, token, checkPath string, checkTLS bool) Consul {
+	scheme := "http://"
+	if checkTLS {
+		scheme = "https://"
+	}
+
+	return Consul{
+		address:     strings.TrimSuffix(address, "/"),
+		token:       token,
+		checkScheme: scheme,
+		checkPath:   checkPath,
+		client:      tracing.NewHTTPClient(5 * time.Second),
+	}
+}
+
+type consulCheck struct {
+	HTTP                           string `json:"HTTP"`
+	Interval                       string `json:"Interval"`
+	Timeout                        string `json:"Timeout"`
+	DeregisterCriticalServiceAfter string `json:"DeregisterCriticalServiceAfter"`
+}
+
+type consulService struct {
+	ID      string            `json:"ID"`
+	Service string            `json:"Service,omitempty"`
+	Name    string            `json:"Name,omitempty"`
+	Address string            `json:"Address"`
+	Port    int               `json:"Port"`
+	Meta    map[string]string `json:"Meta,omitempty"`
+	Check   *consulCheck      `json:"Check,omitempty"`
+}
+
+type consulEntry struct {
+	Node struct {
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service consulService `json:"Service"`
+}
+
+func (self Consul) Register(ctx context.Context, instance Instance) error {
+	service := consulService{
+		ID:      instance.ID,
+		Name:    instance.Name,
+		Address: instance.Host,
+		Port:    instance.Port,
+		Check: &consulCheck{
+			HTTP:                           self.checkScheme + instance.Address() + self.checkPath,
+			Interval:                       "10s",
+			Timeout:                        "2s",
+			DeregisterCriticalServiceAfter: "1m",
+		},
+	}
+	if instance.GRPCPort != 0 {
+		service.Meta = map[string]string{consulGRPCPortMeta: strconv.Itoa(instance.GRPCPort)}
+	}
+
+	return self.do(ctx, http.MethodPut, "/v1/agent/service/register", service, nil)
+}
+
+func (self Consul) Deregister(ctx context.Context, instance Instance) error {
+	return self.do(ctx, http.MethodPut, "/v1/agent/service/deregister/"+url.PathEscape(instance.ID), nil, nil)
+}
+
+func (self Consul) Resolve(ctx context.Context, name string) ([]Instance, error) {
+	entries := []consulEntry{}
+	path := "/v1/health/service/" + url.PathEscape(name) + "?passing=true"
+	if err := self.do(ctx, http.MethodGet, path, nil, &entries); err != nil {
+		return nil, err
+	}
+
+	instances := make([]Instance, 0, len(entries))
+	for _, entry := range entries {
+		instance := Instance{
+			ID:   entry.Service.ID,
+			Name: name,
+			Host: entry.Service.Address,
+			Port: entry.Service.Port,
+		}
+		if instance.Host == "" {
+			instance.Host = entry.Node.Address
+		}
+		instance.GRPCPort, _ = strconv.Atoi(entry.Service.Meta[consulGRPCPortMeta])
+		instances = append(instances, instance)
+	}
+	if len(instances) == 0 {
+		return nil, ErrNoInstance
+	}
+
+	return instances, nil
+}
+
+func (self Consul) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return errors.New(err.Error())
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, self.address+path, reader)
+	if err != nil {
+		return errors.New(err.Error())
+	}
+	if self.token != "" {
+		request.Header.Set(CONSUL_TOKEN_HEADER, self.token)
+	}
+
+	response, err := self.client.Do(request)
+	if err != nil {
+		return errors.New(err.Error())
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1024))
+		return errors.New(fmt.Sprintf("consul %s %s: %d %s", method, path, response.StatusCode, message))
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(response.Body).Decode(out); err != nil {
+		return errors.New(err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"microtecture/infrastructure/config"
+)
+
+// ErrNoInstance is returned when service has no instance to call
+var ErrNoInstance = errors.New("service has no instance")
+
+// Instance is a running instance of a service
+type Instance struct {
+	ID       string `yaml:"id" json:"id"`
+	Name     string `yaml:"name" json:"name"`
+	Host     string `yaml:"host" json:"host"`
+	Port     int    `yaml:"port" json:"port"`
+	GRPCPort int    `yaml:"grpc_port" json:"grpcPort,omitempty"`
+}
+
+// Address returns host:port of http api of instance
+func (self Instance) Address() string {
+	return net.JoinHostPort(self.Host, strconv.Itoa(self.Port))
+}
+
+// GRPCAddress returns host:port of grpc server of instance, empty if it has not
+func (self Instance) GRPCAddress() string {
+	if self.GRPCPort == 0 {
+		return ""
+	}
+
+	return net.JoinHostPort(self.Host, strconv.Itoa(self.GRPCPort))
+}
+
+// Registry registers instances of this service and resolves instances of other services
+type Registry interface {
+	// Register announces instance, so other services resolve it
+	Register(ctx context.Context, instance Instance) error
+	// Deregister withdraws instance
+	Deregister(ctx context.Context, instance Instance) error
+	// Resolve returns instances of service by its name, ErrNoInstance if it has none
+	Resolve(ctx context.Context, name string) ([]Instance, error)
+}
+
+// New creates and returns registry of backend of config, nil if discovery is disabled.
+// instances that remote backends resolve are cached for cache ttl.
+func New(conf config.ApplicationConfig) (Registry, error) {
+	ttl := time.Duration(conf.Discovery.CacheTTL) * time.Second
+
+	switch conf.Discovery.Backend {
+	case config.DISCOVERY_STATIC:
+		return newCache(NewStatic(conf.Discovery.File), ttl), nil
+	case config.DISCOVERY_DNS:
+		return newCache(NewDNS(conf.Discovery.Domain), ttl), nil
+	case config.DISCOVERY_CONSUL:
+		consul := NewConsul(
+			conf.Discovery.Consul, conf.Discovery.Token, conf.Discovery.CheckPath, conf.Discovery.CheckTLS,
+		)
+		return newCache(consul, ttl), nil
+	case config.DISCOVERY_MEMORY:
+		return NewMemory(), nil
+	}
+
+	return nil, nil
+}
+
+// Self returns instance of this service by its name, port and grpc port of config
+// at host of discovery config or hostname
+func Self(conf config.ApplicationConfig) (Instance, error) {
+	host := conf.Discovery.Host
+	if host == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return Instance{}, errors.New(err.Error())
+		}
+		host = hostname
+	}
+
+	instance := Instance{
+		Name: config.NAME,
+		Host: host,
+		Port: int(conf.Port),
+	}
+	if conf.GRPC.Enabled() {
+		instance.GRPCPort = int(conf.GRPC.Port)
+	}
+	instance.ID = config.NAME + "-" + instance.Address()
+
+	return instance, nil
+}
+
+// cache is registry that caches instances that it resolves for ttl
+type cache struct {
+	Registry
+	ttl     time.Duration
+	mutex   *sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	instances []Instance
+	expiresAt time.Time
+}
+
+func newCache(registry Registry, ttl time.Duration) cache {
+	return cache{
+		Registry: registry,
+		ttl:      ttl,
+		mutex:    &sync.Mutex{},
+		entries:  make(map[string]cacheEntry),
+	}
+}
+
+func (self cache) Resolve(ctx context.Context, name string) ([]Instance, error) {
+	self.mutex.Lock()
+	entry, ok := self.entries[name]
+	self.mutex.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.instances, nil
+	}
+
+	instances, err := self.Registry.Resolve(ctx, name)
+	if err != nil {
+		// last known instances are better than none while backend is unreachable
+		if ok && err != ErrNoInstance {
+			return entry.instances, nil
+		}
+		return nil, err
+	}
+
+	self.mutex.Lock()
+	self.entries[name] = cacheEntry{instances, time.Now().Add(self.ttl)}
+	self.mutex.Unlock()
+
+	return instances, nil
+}
@@ -0,0 +1,75 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert"
+	"github.com/pkg/errors"
+)
+
+// unreachable is registry whose backend fails while down is set
+type unreachable struct {
+	*Memory
+	down bool
+}
+
+func (self *unreachable) Resolve(ctx context.Context, name string) ([]Instance, error) {
+	if self.down {
+		return nil, errors.New("connection refused")
+	}
+
+	return self.Memory.Resolve(ctx, name)
+}
+
+func TestCacheFallsBackToLastInstances(t *testing.T) {
+	ctx := context.Background()
+	backend := &unreachable{Memory: NewMemory()}
+	registry := newCache(backend, 10*time.Millisecond)
+
+	backend.down = true
+	_, err := registry.Resolve(ctx, "users")
+	assert.Error(t, err)
+
+	backend.down = false
+	users := Instance{ID: "users-1", Name: "users", Host: "10.0.0.1", Port: 8080}
+	assert.NoError(t, backend.Register(ctx, users))
+	instances, err := registry.Resolve(ctx, "users")
+	assert.NoError(t, err)
+	assert.Equal(t, []Instance{users}, instances)
+
+	// cached instances are served while backend is unreachable, after their ttl too
+	time.Sleep(20 * time.Millisecond)
+	backend.down = true
+	instances, err = registry.Resolve(ctx, "users")
+	assert.NoError(t, err)
+	assert.Equal(t, []Instance{users}, instances)
+
+	// a backend that answers no instance is trusted
+	backend.down = false
+	assert.NoError(t, backend.Deregister(ctx, users))
+	_, err = registry.Resolve(ctx, "users")
+	assert.Equal(t, ErrNoInstance, err)
+}
+
+func TestConsulRegistersCheckOfConfig(t *testing.T) {
+	var service consulService
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/agent/service/register", r.URL.Path)
+		assert.Equal(t, "secret", r.Header.Get(CONSUL_TOKEN_HEADER))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&service))
+	}))
+	defer agent.Close()
+
+	consul := NewConsul(agent.URL+"/", "secret", "/health/ready", true)
+	err := consul.Register(context.Background(), Instance{
+		ID: "users-1", Name: "users", Host: "10.0.0.1", Port: 8443, GRPCPort: 9000,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://10.0.0.1:8443/health/ready", service.Check.HTTP)
+	assert.Equal(t, "9000", service.Meta[consulGRPCPortMeta])
+}
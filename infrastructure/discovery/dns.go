@@ -0,0 +1,66 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DNS is registry that resolves instances by SRV records _http._tcp and _grpc._tcp
+// of name.domain, like named ports of kubernetes services. records are managed
+// by dns server, so instances are not registered.
+type DNS struct {
+	domain   string
+	resolver *net.Resolver
+}
+
+// NewDNS creates and returns registry of domain
+func NewDNS(domain string) DNS {
+	return DNS{strings.Trim(domain, "."), net.DefaultResolver}
+}
+
+func (self DNS) Register(ctx context.Context, instance Instance) error {
+	return nil
+}
+
+func (self DNS) Deregister(ctx context.Context, instance Instance) error {
+	return nil
+}
+
+func (self DNS) Resolve(ctx context.Context, name string) ([]Instance, error) {
+	domain := name + "." + self.domain
+
+	_, records, err := self.resolver.LookupSRV(ctx, "http", "tcp", domain)
+	if e, ok := err.(*net.DNSError); ok && e.IsNotFound {
+		return nil, ErrNoInstance
+	}
+	if err != nil {
+		return nil, errors.New(err.Error())
+	}
+
+	grpcPorts := make(map[string]int)
+	if _, grpcRecords, err := self.resolver.LookupSRV(ctx, "grpc", "tcp", domain); err == nil {
+		for _, record := range grpcRecords {
+			grpcPorts[strings.TrimSuffix(record.Target, ".")] = int(record.Port)
+		}
+	}
+
+	instances := make([]Instance, 0, len(records))
+	for _, record := range records {
+		instance := Instance{
+			Name:     name,
+			Host:     strings.TrimSuffix(record.Target, "."),
+			Port:     int(record.Port),
+			GRPCPort: grpcPorts[strings.TrimSuffix(record.Target, ".")],
+		}
+		instance.ID = name + "-" + instance.Address()
+		instances = append(instances, instance)
+	}
+	if len(instances) == 0 {
+		return nil, ErrNoInstance
+	}
+
+	return instances, nil
+}
@@ -0,0 +1,60 @@
+package discovery
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Memory is registry of instances in memory of this process, for test goals
+type Memory struct {
+	mutex     *sync.RWMutex
+	instances map[string]map[string]Instance
+}
+
+// NewMemory creates and returns empty memory registry
+func NewMemory() *Memory {
+	return &Memory{
+		mutex:     &sync.RWMutex{},
+		instances: make(map[string]map[string]Instance),
+	}
+}
+
+func (self *Memory) Register(ctx context.Context, instance Instance) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	if self.instances[instance.Name] == nil {
+		self.instances[instance.Name] = make(map[string]Instance)
+	}
+	self.instances[instance.Name][instance.ID] = instance
+
+	return nil
+}
+
+func (self *Memory) Deregister(ctx context.Context, instance Instance) error {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	delete(self.instances[instance.Name], instance.ID)
+
+	return nil
+}
+
+func (self *Memory) Resolve(ctx context.Context, name string) ([]Instance, error) {
+	self.mutex.RLock()
+	defer self.mutex.RUnlock()
+
+	if len(self.instances[name]) == 0 {
+		return nil, ErrNoInstance
+	}
+
+	instances := make([]Instance, 0, len(self.instances[name]))
+	for _, instance := range self.instances[name] {
+		instances = append(instances, instance)
+	}
+	// instances are sorted like remote backends return them, so clients take them in turn
+	sort.Slice(instances, func(i, j int) bool { return instances[i].ID < instances[j].ID })
+
+	return instances, nil
+}
@@ -0,0 +1,59 @@
+package discovery
+
+import (
+	"context"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Static is registry of instances listed in a yaml file by service name, like
+//
+//	users:
+//	  - host: users-1
+//	    port: 8080
+//	    grpc_port: 9000
+//
+// file is read on every resolve, so it is edited without restart. instances are not registered.
+type Static struct {
+	file string
+}
+
+// NewStatic creates and returns registry of file
+func NewStatic(file string) Static {
+	return Static{file}
+}
+
+func (self Static) Register(ctx context.Context, instance Instance) error {
+	return nil
+}
+
+func (self Static) Deregister(ctx context.Context, instance Instance) error {
+	return nil
+}
+
+func (self Static) Resolve(ctx context.Context, name string) ([]Instance, error) {
+	content, err := ioutil.ReadFile(self.file)
+	if err != nil {
+		return nil, errors.New(err.Error())
+	}
+
+	services := make(map[string][]Instance)
+	if err := yaml.Unmarshal(content, &services); err != nil {
+		return nil, errors.New(err.Error())
+	}
+
+	instances := services[name]
+	if len(instances) == 0 {
+		return nil, ErrNoInstance
+	}
+	for i := range instances {
+		instances[i].Name = name
+		if instances[i].ID == "" {
+			instances[i].ID = name + "-" + instances[i].Address()
+		}
+	}
+
+	return instances, nil
+}
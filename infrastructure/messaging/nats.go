@@ -0,0 +1,73 @@
+package messaging
+
+import (
+	"context"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"microtecture/infrastructure/config"
+)
+
+// Connect connects to nats servers of config, it keeps retrying if servers are not reachable
+// at start and reconnects forever when connection is lost
+func Connect(conf config.ApplicationConfig) (*nats.Conn, error) {
+	options := []nats.Option{
+		nats.Name(config.NAME),
+		nats.MaxReconnects(-1),
+		nats.RetryOnFailedConnect(true),
+		nats.DrainTimeout(time.Duration(conf.NATS.DrainTimeout) * time.Second),
+		nats.DisconnectErrHandler(func(conn *nats.Conn, err error) {
+			if err != nil {
+				logrus.Warning("nats disconnected: ", err)
+			}
+		}),
+		nats.ReconnectHandler(func(conn *nats.Conn) {
+			logrus.Info("nats reconnected to ", conn.ConnectedUrl())
+		}),
+		nats.ErrorHandler(func(conn *nats.Conn, sub *nats.Subscription, err error) {
+			logrus.Error("nats: ", err)
+		}),
+	}
+
+	if conf.NATS.Credentials != "" {
+		options = append(options, nats.UserCredentials(conf.NATS.Credentials))
+	}
+
+	conn, err := nats.Connect(conf.NATS.URL, options...)
+	if err != nil {
+		return nil, errors.New(err.Error())
+	}
+
+	return conn, nil
+}
+
+// Check returns readiness check of connection, it fails while connection is not established
+func Check(conn *nats.Conn) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if status := conn.Status(); status != nats.CONNECTED {
+			return errors.New("nats is not connected: " + statusText(status))
+		}
+
+		return nil
+	}
+}
+
+func statusText(status nats.Status) string {
+	switch status {
+	case nats.DISCONNECTED:
+		return "disconnected"
+	case nats.CONNECTING:
+		return "connecting"
+	case nats.RECONNECTING:
+		return "reconnecting"
+	case nats.DRAINING_SUBS, nats.DRAINING_PUBS:
+		return "draining"
+	case nats.CLOSED:
+		return "closed"
+	}
+
+	return "unknown"
+}
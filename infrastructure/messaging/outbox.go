@@ -0,0 +1,274 @@
+package messaging
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"microtecture/domain/models"
+	"microtecture/infrastructure/config"
+	"microtecture/infrastructure/metrics"
+	repository "microtecture/usecase/repositories"
+)
+
+const (
+	EVENT_TYPE_HEADER     = "Event-Type"
+	AGGREGATE_TYPE_HEADER = "Aggregate-Type"
+	AGGREGATE_ID_HEADER   = "Aggregate-Id"
+
+	publishTimeout = 5 * time.Second
+	// cleanupInterval is time between deletions of published events that are retained enough
+	cleanupInterval = time.Hour
+)
+
+// Relay publishes pending events of outbox to nats jetstream after their transaction commits.
+// an event is published at least once, with its id as message id so jetstream drops duplicates
+// in its window, and events of an aggregate are published in order of their sequence.
+//
+//	err := app.Transactions.Transaction(ctx, func(ctx context.Context) error {
+//		if err := app.Users.Create(ctx, user); err != nil {
+//			return err
+//		}
+//		event, err := models.NewOutboxEvent("user", user.Id.String(), "user.created", user)
+//		if err != nil {
+//			return err
+//		}
+//		return app.Outbox.Add(ctx, event)
+//	})
+type Relay struct {
+	outbox      repository.OutboxRepository
+	js          nats.JetStreamContext
+	logger      logrus.FieldLogger
+	interval    time.Duration
+	batchSize   int
+	maxAttempts uint
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	retention   time.Duration
+	subject     string
+}
+
+// NewRelay creates and returns relay of outbox to jetstream of connection, it creates stream
+// of outbox if it does not exist and fails if jetstream is not enabled or stream does not capture subject
+func NewRelay(
+	conf config.ApplicationConfig, conn *nats.Conn, outbox repository.OutboxRepository, logger logrus.FieldLogger,
+) (Relay, error) {
+	js, err := conn.JetStream()
+	if err != nil {
+		return Relay{}, errors.New(err.Error())
+	}
+	if err := provision(js, conf.Outbox.Stream, conf.Outbox.Subject); err != nil {
+		return Relay{}, err
+	}
+
+	return Relay{
+		outbox:      outbox,
+		js:          js,
+		logger:      logger,
+		interval:    time.Duration(conf.Outbox.Interval) * time.Second,
+		batchSize:   int(conf.Outbox.BatchSize),
+		maxAttempts: conf.Outbox.MaxAttempts,
+		backoff:     time.Duration(conf.Outbox.Backoff) * time.Second,
+		maxBackoff:  time.Duration(conf.Outbox.MaxBackoff) * time.Second,
+		retention:   time.Duration(conf.Outbox.Retention) * time.Second,
+		subject:     conf.Outbox.Subject,
+	}, nil
+}
+
+// Run relays events every interval, or at once while batches are full,
+// and deletes published events older than retention until ctx is done
+func (self Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(self.interval)
+	defer ticker.Stop()
+
+	var cleanedAt time.Time
+	for {
+		count, err := self.RelayOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			self.logger.Error("outbox relay: ", err)
+		}
+
+		if time.Since(cleanedAt) >= cleanupInterval {
+			deleted, err := self.outbox.DeletePublished(ctx, time.Now().Add(-self.retention))
+			if err != nil && ctx.Err() == nil {
+				self.logger.Error("outbox cleanup: ", err)
+			}
+			if deleted > 0 {
+				self.logger.Infof("outbox cleanup deleted %d published events", deleted)
+			}
+			cleanedAt = time.Now()
+		}
+
+		if err == nil && count == self.batchSize {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayOnce publishes a batch of due events and returns count of events that it handles.
+// an event that fails is retried after backoff and later events of its aggregate wait for it,
+// it is dead after max attempts and kept, with later events of its aggregate, until it is requeued.
+func (self Relay) RelayOnce(ctx context.Context) (int, error) {
+	count := 0
+	_, err := self.outbox.Relay(ctx, self.batchSize, func(ctx context.Context, events []models.OutboxEvent) error {
+		count = len(events)
+		failed := make(map[string]bool)
+		published := []uuid.UUID{}
+		for i := range events {
+			event := &events[i]
+			aggregate := event.AggregateType + "/" + event.AggregateID
+			if failed[aggregate] {
+				continue
+			}
+
+			err := self.publish(ctx, event)
+			if err == nil {
+				published = append(published, event.Id)
+				continue
+			}
+			// events are not failed by shutdown, they are relayed again on next start
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			failed[aggregate] = true
+			if err := self.fail(ctx, event, err); err != nil {
+				return err
+			}
+		}
+
+		if err := self.outbox.MarkPublished(ctx, published); err != nil {
+			return err
+		}
+		metrics.ObserveOutbox(metrics.OUTBOX_PUBLISHED, len(published))
+
+		return nil
+	})
+
+	return count, err
+}
+
+// Subject returns subject that event is published to
+func (self Relay) Subject(event models.OutboxEvent) string {
+	return self.subject + "." + event.Type
+}
+
+func (self Relay) publish(ctx context.Context, event *models.OutboxEvent) error {
+	msg := nats.NewMsg(self.Subject(*event))
+	msg.Data = event.Payload
+	msg.Header.Set(nats.MsgIdHdr, event.Id.String())
+	msg.Header.Set(EVENT_TYPE_HEADER, event.Type)
+	msg.Header.Set(AGGREGATE_TYPE_HEADER, event.AggregateType)
+	msg.Header.Set(AGGREGATE_ID_HEADER, event.AggregateID)
+
+	ctx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+
+	if _, err := self.js.PublishMsg(msg, nats.Context(ctx)); err != nil {
+		return errors.New(err.Error())
+	}
+
+	return nil
+}
+
+// fail saves failed attempt of event, it is dead if it has no attempts left
+func (self Relay) fail(ctx context.Context, event *models.OutboxEvent, err error) error {
+	event.Attempts++
+	event.LastError = err.Error()
+	event.NextAttemptAt = time.Now().Add(self.backoffOf(event.Attempts))
+
+	logger := self.logger.WithFields(logrus.Fields{
+		"event":     event.Id.String(),
+		"type":      event.Type,
+		"aggregate": event.AggregateType + "/" + event.AggregateID,
+		"attempts":  event.Attempts,
+	})
+	if event.Attempts >= self.maxAttempts {
+		now := time.Now()
+		event.DeadAt = &now
+		logger.Error("outbox event is dead: ", err)
+		metrics.ObserveOutbox(metrics.OUTBOX_DEAD, 1)
+	} else {
+		logger.Warning("outbox event failed: ", err)
+		metrics.ObserveOutbox(metrics.OUTBOX_FAILED, 1)
+	}
+
+	return self.outbox.MarkFailed(ctx, event)
+}
+
+// provision creates stream that captures subject.> if it does not exist,
+// events are not relayed to an existing stream that does not capture it
+func provision(js nats.JetStreamContext, stream, subject string) error {
+	info, err := js.StreamInfo(stream)
+	if err == nats.ErrStreamNotFound {
+		_, err := js.AddStream(&nats.StreamConfig{
+			Name:     stream,
+			Subjects: []string{subject + ".>"},
+			Storage:  nats.FileStorage,
+		})
+		if err != nil {
+			return errors.New("outbox stream " + stream + " can not be created: " + err.Error())
+		}
+		return nil
+	}
+	if err != nil {
+		return errors.New("outbox stream " + stream + " can not be read: " + err.Error())
+	}
+
+	for _, captured := range info.Config.Subjects {
+		if captures(captured, subject+".>") {
+			return nil
+		}
+	}
+
+	return errors.New(
+		"outbox stream " + stream + " captures " + strings.Join(info.Config.Subjects, ", ") +
+			" but not " + subject + ".>",
+	)
+}
+
+// captures reports whether subjects of pattern include every subject of wildcard subject
+func captures(pattern, subject string) bool {
+	patternTokens := strings.Split(pattern, ".")
+	subjectTokens := strings.Split(subject, ".")
+	for i, token := range patternTokens {
+		if token == ">" {
+			return true
+		}
+		if i >= len(subjectTokens) || subjectTokens[i] == ">" {
+			return false
+		}
+		if token != "*" && token != subjectTokens[i] {
+			return false
+		}
+	}
+
+	return len(patternTokens) == len(subjectTokens)
+}
+
+// backoffOf returns wait after attempt, it doubles every attempt up to max backoff
+func (self Relay) backoffOf(attempt uint) time.Duration {
+	wait := self.backoff
+	for i := uint(1); i < attempt && wait < self.maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > self.maxBackoff {
+		wait = self.maxBackoff
+	}
+
+	return wait
+}
@@ -0,0 +1,254 @@
+package messaging
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert"
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/sirupsen/logrus"
+
+	"microtecture/domain/models"
+	"microtecture/infrastructure/config"
+	"microtecture/infrastructure/datastore"
+	repository "microtecture/usecase/repositories"
+)
+
+func newTestNats(t *testing.T, jetStream bool) *nats.Conn {
+	storeDir, err := ioutil.TempDir("", "nats")
+	assert.NoError(t, err)
+
+	s, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: jetStream,
+		StoreDir:  storeDir,
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	assert.NoError(t, err)
+	go s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("nats server is not ready")
+	}
+
+	conn, err := nats.Connect(s.ClientURL())
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		conn.Close()
+		s.Shutdown()
+		s.WaitForShutdown()
+		os.RemoveAll(storeDir)
+	})
+
+	return conn
+}
+
+func newTestRelay(t *testing.T, conn *nats.Conn, outbox repository.OutboxRepository) Relay {
+	conf := config.ApplicationConfig{}
+	conf.Outbox.BatchSize = 10
+	conf.Outbox.MaxAttempts = 2
+	conf.Outbox.Retention = 3600
+	conf.Outbox.Subject = "test"
+	conf.Outbox.Stream = "TEST"
+
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+
+	relay, err := NewRelay(conf, conn, outbox, logger)
+	assert.NoError(t, err)
+	relay.interval = 10 * time.Millisecond
+	relay.backoff = time.Millisecond
+	relay.maxBackoff = time.Millisecond
+
+	return relay
+}
+
+func addTestEvent(t *testing.T, outbox repository.OutboxRepository, aggregateID, payload string) *models.OutboxEvent {
+	event, err := models.NewOutboxEvent("user", aggregateID, "user.updated", payload)
+	assert.NoError(t, err)
+	assert.NoError(t, outbox.Add(context.Background(), event))
+
+	return event
+}
+
+// nextIDs returns ids of next count messages of subscription
+func nextIDs(t *testing.T, sub *nats.Subscription, count int) []string {
+	ids := []string{}
+	for i := 0; i < count; i++ {
+		msg, err := sub.NextMsg(time.Second)
+		assert.NoError(t, err)
+		ids = append(ids, msg.Header.Get(nats.MsgIdHdr))
+	}
+
+	return ids
+}
+
+func TestNewRelayProvisionsStream(t *testing.T) {
+	conn := newTestNats(t, true)
+	newTestRelay(t, conn, datastore.NewMemoryOutboxRepository())
+
+	js, err := conn.JetStream()
+	assert.NoError(t, err)
+	info, err := js.StreamInfo("TEST")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"test.>"}, info.Config.Subjects)
+
+	// existing stream is kept
+	newTestRelay(t, conn, datastore.NewMemoryOutboxRepository())
+
+	conf := config.ApplicationConfig{}
+	conf.Outbox.Subject = "other"
+	conf.Outbox.Stream = "TEST"
+	_, err = NewRelay(conf, conn, datastore.NewMemoryOutboxRepository(), logrus.New())
+	assert.EqualError(t, err, "outbox stream TEST captures test.> but not other.>")
+}
+
+func TestNewRelayFailsWithoutJetStream(t *testing.T) {
+	conn := newTestNats(t, false)
+
+	conf := config.ApplicationConfig{}
+	conf.Outbox.Subject = "test"
+	conf.Outbox.Stream = "TEST"
+	_, err := NewRelay(conf, conn, datastore.NewMemoryOutboxRepository(), logrus.New())
+	assert.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "outbox stream TEST can not be read: "), err.Error())
+}
+
+func TestCaptures(t *testing.T) {
+	assert.True(t, captures("test.>", "test.>"))
+	assert.True(t, captures(">", "test.>"))
+	assert.True(t, captures("*.>", "org.test.>"))
+	assert.True(t, captures("org.>", "org.test.>"))
+	assert.False(t, captures("test.*", "test.>"))
+	assert.False(t, captures("test.user.>", "test.>"))
+	assert.False(t, captures("other.>", "test.>"))
+}
+
+func TestRelayPublishesEventsInOrder(t *testing.T) {
+	conn := newTestNats(t, true)
+	outbox := datastore.NewMemoryOutboxRepository()
+	relay := newTestRelay(t, conn, outbox)
+
+	first := addTestEvent(t, outbox, "1", "first")
+	other := addTestEvent(t, outbox, "2", "other")
+	second := addTestEvent(t, outbox, "1", "second")
+
+	count, err := relay.RelayOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	js, err := conn.JetStream()
+	assert.NoError(t, err)
+	sub, err := js.SubscribeSync("test.>", nats.DeliverAll())
+	assert.NoError(t, err)
+
+	msg, err := sub.NextMsg(time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "test.user.updated", msg.Subject)
+	assert.Equal(t, `"first"`, string(msg.Data))
+	assert.Equal(t, first.Id.String(), msg.Header.Get(nats.MsgIdHdr))
+	assert.Equal(t, "user.updated", msg.Header.Get(EVENT_TYPE_HEADER))
+	assert.Equal(t, "user", msg.Header.Get(AGGREGATE_TYPE_HEADER))
+	assert.Equal(t, "1", msg.Header.Get(AGGREGATE_ID_HEADER))
+	assert.Equal(t, []string{other.Id.String(), second.Id.String()}, nextIDs(t, sub, 2))
+
+	// published events are not relayed again
+	count, err = relay.RelayOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+func TestRelayDeadLettersFailedEvent(t *testing.T) {
+	conn := newTestNats(t, true)
+	js, err := conn.JetStream()
+	assert.NoError(t, err)
+	// stream rejects large events so they fail
+	_, err = js.AddStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"test.>"}, MaxMsgSize: 512})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	outbox := datastore.NewMemoryOutboxRepository()
+	relay := newTestRelay(t, conn, outbox)
+
+	large := addTestEvent(t, outbox, "1", strings.Repeat("a", 1024))
+	waiting := addTestEvent(t, outbox, "1", "waiting")
+	other := addTestEvent(t, outbox, "2", "other")
+
+	sub, err := js.SubscribeSync("test.>", nats.DeliverAll())
+	assert.NoError(t, err)
+
+	// later event of failed aggregate waits for it, other aggregates are published
+	count, err := relay.RelayOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.Equal(t, []string{other.Id.String()}, nextIDs(t, sub, 1))
+
+	count, err = relay.RelayOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	// failed event is retried after backoff and is dead after max attempts
+	time.Sleep(5 * time.Millisecond)
+	count, err = relay.RelayOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	_, err = sub.NextMsg(100 * time.Millisecond)
+	assert.Equal(t, nats.ErrTimeout, err)
+
+	// dead event is kept and later events of its aggregate wait for it
+	count, err = relay.RelayOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	// requeued event is published once stream accepts it, and then events that wait for it
+	_, err = js.UpdateStream(&nats.StreamConfig{Name: "TEST", Subjects: []string{"test.>"}, MaxMsgSize: 2048})
+	assert.NoError(t, err)
+	assert.NoError(t, outbox.Requeue(ctx, large.Id))
+	assert.Equal(t, repository.ErrOutboxEventNotFound, outbox.Requeue(ctx, large.Id))
+
+	count, err = relay.RelayOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, []string{large.Id.String(), waiting.Id.String()}, nextIDs(t, sub, 2))
+}
+
+// deletingOutbox counts events that are deleted by cleanup of relay
+type deletingOutbox struct {
+	repository.OutboxRepository
+	deleted int64
+}
+
+func (self *deletingOutbox) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	deleted, err := self.OutboxRepository.DeletePublished(ctx, before)
+	self.deleted += deleted
+
+	return deleted, err
+}
+
+func TestRelayDeletesPublishedEventsAfterRetention(t *testing.T) {
+	conn := newTestNats(t, true)
+	outbox := &deletingOutbox{OutboxRepository: datastore.NewMemoryOutboxRepository()}
+	relay := newTestRelay(t, conn, outbox)
+
+	addTestEvent(t, outbox, "1", "first")
+	addTestEvent(t, outbox, "2", "second")
+
+	// events that are published within retention are kept
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	relay.Run(ctx)
+	assert.Equal(t, int64(0), outbox.deleted)
+
+	// events that are published before retention are deleted on start of relay
+	relay.retention = 0
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	relay.Run(ctx)
+	assert.Equal(t, int64(2), outbox.deleted)
+}
@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"microtecture/infrastructure/config"
+	"microtecture/infrastructure/datastore"
+)
+
+const (
+	AUTH_SUCCESS      = "success"
+	AUTH_UNAUTHORIZED = "unauthorized"
+	AUTH_FORBIDDEN    = "forbidden"
+	AUTH_REFRESHED    = "refreshed"
+
+	UNKNOWN_ROUTE = "unknown"
+
+	OUTBOX_PUBLISHED = "published"
+	OUTBOX_FAILED    = "failed"
+	OUTBOX_DEAD      = "dead"
+)
+
+var (
+	httpRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: config.NAME,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Count of served http requests by route, method and status code.",
+		},
+		[]string{"route", "method", "status"},
+	)
+
+	httpDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: config.NAME,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Latency of served http requests by route, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"route", "method", "status"},
+	)
+
+	authOutcomes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: config.NAME,
+			Subsystem: "auth",
+			Name:      "outcomes_total",
+			Help:      "Count of authorizations by outcome: success, unauthorized, forbidden or refreshed.",
+		},
+		[]string{"outcome"},
+	)
+
+	outboxEvents = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: config.NAME,
+			Subsystem: "outbox",
+			Name:      "events_total",
+			Help:      "Count of relayed outbox events by outcome: published, failed or dead.",
+		},
+		[]string{"outcome"},
+	)
+
+	buildInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: config.NAME,
+			Name:      "build_info",
+			Help:      "Version of application and go that it is built with, always 1.",
+		},
+		[]string{"version", "goversion"},
+	)
+)
+
+func init() {
+	buildInfo.WithLabelValues(config.VERSION, runtime.Version()).Set(1)
+
+	// outcomes are exported from start even if nothing is authorized yet
+	for _, outcome := range []string{AUTH_SUCCESS, AUTH_UNAUTHORIZED, AUTH_FORBIDDEN, AUTH_REFRESHED} {
+		authOutcomes.WithLabelValues(outcome)
+	}
+	for _, outcome := range []string{OUTBOX_PUBLISHED, OUTBOX_FAILED, OUTBOX_DEAD} {
+		outboxEvents.WithLabelValues(outcome)
+	}
+}
+
+// ObserveRequest records count and latency of served http request,
+// route is matched route pattern like /users/:id to keep label values few
+func ObserveRequest(route, method string, status int, duration time.Duration) {
+	if route == "" {
+		route = UNKNOWN_ROUTE
+	}
+	labels := []string{route, method, strconv.Itoa(status)}
+
+	httpRequests.WithLabelValues(labels...).Inc()
+	httpDuration.WithLabelValues(labels...).Observe(duration.Seconds())
+}
+
+// ObserveAuth counts outcome of an authorization
+func ObserveAuth(outcome string) {
+	authOutcomes.WithLabelValues(outcome).Inc()
+}
+
+// ObserveOutbox counts count of outbox events with outcome
+func ObserveOutbox(outcome string, count int) {
+	outboxEvents.WithLabelValues(outcome).Add(float64(count))
+}
+
+// NewRegistry creates and returns registry of application metrics, connection pool stats
+// of sql session and go runtime and process metrics
+func NewRegistry(session datastore.Session) *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		httpRequests,
+		httpDuration,
+		authOutcomes,
+		outboxEvents,
+		buildInfo,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	if session.SQLSession.DB != nil {
+		registry.MustRegister(collectors.NewDBStatsCollector(session.SQLSession.DB.DB(), "postgres"))
+	}
+
+	return registry
+}
+
+// Handler returns http handler that exposes metrics of registry in prometheus text format
+func Handler(registry *prometheus.Registry) http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/alecthomas/assert"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"microtecture/infrastructure/config"
+	"microtecture/infrastructure/datastore"
+)
+
+// scrape returns metric families that handler of registry exposes
+func scrape(t *testing.T) map[string]*dto.MetricFamily {
+	recorder := httptest.NewRecorder()
+	Handler(NewRegistry(datastore.Session{})).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	parser := expfmt.TextParser{}
+	families, err := parser.TextToMetricFamilies(recorder.Body)
+	assert.NoError(t, err)
+
+	return families
+}
+
+// metric returns metric of family whose labels have values, nil if there is none
+func metric(families map[string]*dto.MetricFamily, name string, labels map[string]string) *dto.Metric {
+	family, ok := families[name]
+	if !ok {
+		return nil
+	}
+
+	for _, m := range family.Metric {
+		matched := 0
+		for _, label := range m.Label {
+			if value, ok := labels[label.GetName()]; ok && value == label.GetValue() {
+				matched++
+			}
+		}
+		if matched == len(labels) {
+			return m
+		}
+	}
+
+	return nil
+}
+
+func TestObserveRequest(t *testing.T) {
+	labels := map[string]string{"route": "/metrics-test/:id", "method": http.MethodGet, "status": "201"}
+	ObserveRequest("/metrics-test/:id", http.MethodGet, http.StatusCreated, 30*time.Millisecond)
+
+	families := scrape(t)
+	assert.Equal(t, float64(1), metric(families, "microtecture_http_requests_total", labels).GetCounter().GetValue())
+	histogram := metric(families, "microtecture_http_request_duration_seconds", labels).GetHistogram()
+	assert.Equal(t, uint64(1), histogram.GetSampleCount())
+	assert.Equal(t, 0.03, histogram.GetSampleSum())
+
+	// requests without route share one label value
+	ObserveRequest("", http.MethodGet, http.StatusNotFound, time.Millisecond)
+	families = scrape(t)
+	unknown := map[string]string{"route": UNKNOWN_ROUTE, "method": http.MethodGet, "status": "404"}
+	assert.NotZero(t, metric(families, "microtecture_http_requests_total", unknown))
+}
+
+func TestAuthOutcomesAreExportedFromStart(t *testing.T) {
+	families := scrape(t)
+	for _, outcome := range []string{AUTH_SUCCESS, AUTH_UNAUTHORIZED, AUTH_FORBIDDEN, AUTH_REFRESHED} {
+		assert.NotZero(t, metric(families, "microtecture_auth_outcomes_total", map[string]string{"outcome": outcome}), outcome)
+	}
+
+	before := metric(families, "microtecture_auth_outcomes_total", map[string]string{"outcome": AUTH_FORBIDDEN})
+	ObserveAuth(AUTH_FORBIDDEN)
+	after := metric(scrape(t), "microtecture_auth_outcomes_total", map[string]string{"outcome": AUTH_FORBIDDEN})
+	assert.Equal(t, before.GetCounter().GetValue()+1, after.GetCounter().GetValue())
+}
+
+func TestBuildInfo(t *testing.T) {
+	labels := map[string]string{"version": config.VERSION, "goversion": runtime.Version()}
+	info := metric(scrape(t), "microtecture_build_info", labels)
+	assert.NotZero(t, info)
+	assert.Equal(t, float64(1), info.GetGauge().GetValue())
+}
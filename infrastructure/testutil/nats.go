@@ -0,0 +1,117 @@
+package testutil
+
+import (
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+
+	"microtecture/infrastructure/application"
+	"microtecture/interface/controllers"
+	"microtecture/interface/router"
+	uc "microtecture/usecase/controllers"
+)
+
+// NatsServer is embedded nats server with jetstream for test goals
+type NatsServer struct {
+	*server.Server
+	storeDir string
+}
+
+// NewNatsServer starts nats server with jetstream on a random port
+//
+//	s, err := testutil.NewNatsServer()
+//	defer s.Close()
+//	n, err := t.NewNatsController(s)
+//	msg, err := n.GetBase().Application.Nats.Request("microtecture.v1.users.get", data, time.Second)
+func NewNatsServer() (*NatsServer, error) {
+	storeDir, err := ioutil.TempDir("", "nats")
+	if err != nil {
+		return nil, errors.New(err.Error())
+	}
+
+	s, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      server.RANDOM_PORT,
+		JetStream: true,
+		StoreDir:  storeDir,
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		os.RemoveAll(storeDir)
+		return nil, errors.New(err.Error())
+	}
+
+	go s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		s.Shutdown()
+		os.RemoveAll(storeDir)
+		return nil, errors.New("nats server is not ready")
+	}
+
+	return &NatsServer{s, storeDir}, nil
+}
+
+// Connect connects to server
+func (self *NatsServer) Connect() (*nats.Conn, error) {
+	conn, err := nats.Connect(self.ClientURL())
+	if err != nil {
+		return nil, errors.New(err.Error())
+	}
+
+	return conn, nil
+}
+
+// AddStream adds jetstream stream of subjects to server, durable consumers need it
+func (self *NatsServer) AddStream(name string, subjects ...string) error {
+	conn, err := self.Connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	js, err := conn.JetStream()
+	if err != nil {
+		return errors.New(err.Error())
+	}
+
+	if _, err := js.AddStream(&nats.StreamConfig{Name: name, Subjects: subjects}); err != nil {
+		return errors.New(err.Error())
+	}
+
+	return nil
+}
+
+// Close shuts server down and removes its store
+func (self *NatsServer) Close() {
+	self.Shutdown()
+	self.WaitForShutdown()
+	os.RemoveAll(self.storeDir)
+}
+
+// NewNatsController creates nats controller of test application that is connected to server
+// and subscribes its handlers
+func (t *T) NewNatsController(s *NatsServer) (uc.Nats, error) {
+	conn, err := s.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	app := t.Controller.GetBase().Application
+	app.Nats = conn
+	c, err := application.NewController(app)
+	if err != nil {
+		return nil, err
+	}
+
+	n := controllers.NewNats(application.NewNatsController(c), app.Users)
+	if err := router.RouteNats(n); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
@@ -0,0 +1,63 @@
+package testutil
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"microtecture/infrastructure/tracing"
+)
+
+// Spans is in memory exporter of ended spans for test goals
+type Spans struct {
+	*tracetest.InMemoryExporter
+	provider *sdktrace.TracerProvider
+}
+
+// NewSpans sets a tracer provider that samples every span and exports it synchronously
+// to memory as global one, so spans of a request are in Spans right after it is served
+//
+//	spans := testutil.NewSpans()
+//	defer spans.Close()
+//	t.SendRestRequest(nil)
+//	span, ok := spans.Find("POST /api/v1/auth/login")
+func NewSpans() *Spans {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := tracing.NewTracerProvider(
+		sdktrace.WithSyncer(exporter),
+		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+	)
+	otel.SetTracerProvider(provider)
+
+	return &Spans{exporter, provider}
+}
+
+// Find returns first ended span by its name
+func (self *Spans) Find(name string) (tracetest.SpanStub, bool) {
+	for _, span := range self.GetSpans() {
+		if span.Name == name {
+			return span, true
+		}
+	}
+
+	return tracetest.SpanStub{}, false
+}
+
+// Children returns ended spans whose parent is span
+func (self *Spans) Children(parent tracetest.SpanStub) tracetest.SpanStubs {
+	children := tracetest.SpanStubs{}
+	for _, span := range self.GetSpans() {
+		if span.Parent.SpanID() == parent.SpanContext.SpanID() {
+			children = append(children, span)
+		}
+	}
+
+	return children
+}
+
+// Close stops tracer provider of spans
+func (self *Spans) Close() {
+	self.provider.Shutdown(context.Background())
+}
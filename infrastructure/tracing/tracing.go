@@ -0,0 +1,117 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"microtecture/infrastructure/config"
+)
+
+const TRACER_NAME = config.NAME
+
+// Provider is tracer provider of application, nil when tracing is off
+type Provider struct {
+	*sdktrace.TracerProvider
+}
+
+// NewProvider creates tracer provider that exports spans to configured exporter and sets it
+// as global one, W3C trace context and baggage are set as global propagator even if tracing is off
+// so trace context of requests is still propagated. when tracing is off spans are not exported,
+// but every hop still opens its own span so incoming trace context is not forwarded verbatim.
+func NewProvider(conf config.ApplicationConfig) (*Provider, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch conf.Tracing.Exporter {
+	case config.TRACING_OTLP:
+		options := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(conf.Tracing.Endpoint)}
+		if conf.Tracing.Insecure {
+			options = append(options, otlptracegrpc.WithInsecure())
+		}
+		exporter, err = otlptracegrpc.New(context.Background(), options...)
+	case config.TRACING_STDOUT:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		otel.SetTracerProvider(NewTracerProvider(sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.NeverSample()))))
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.New(err.Error())
+	}
+
+	provider := NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(conf.Tracing.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return &Provider{provider}, nil
+}
+
+// NewTracerProvider creates tracer provider with resource of service, options add exporters and samplers
+func NewTracerProvider(options ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	options = append([]sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(config.NAME),
+			semconv.ServiceVersionKey.String(config.VERSION),
+		)),
+	}, options...)
+
+	return sdktrace.NewTracerProvider(options...)
+}
+
+// Shutdown flushes spans that are not exported yet and stops exporter
+func (self *Provider) Shutdown() error {
+	if self == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := self.TracerProvider.Shutdown(ctx); err != nil {
+		return errors.New(err.Error())
+	}
+
+	return nil
+}
+
+// Tracer returns tracer of application from global tracer provider
+func Tracer() trace.Tracer {
+	return otel.Tracer(TRACER_NAME)
+}
+
+// NewTransport wraps base round tripper, http.DefaultTransport if nil, to open a client span
+// for every outbound request and inject trace context of request context to its headers
+func NewTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return otelhttp.NewTransport(base)
+}
+
+// NewHTTPClient creates http client whose requests are traced by NewTransport
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: NewTransport(nil),
+		Timeout:   timeout,
+	}
+}
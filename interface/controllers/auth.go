@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"net/http"
+
+	"microtecture/domain/models"
+	"microtecture/infrastructure/application"
+	repository "microtecture/usecase/repositories"
+)
+
+// dummyPassword is bcrypt hash that passwords are checked against when mobile number
+// is not registered, so response time does not tell which numbers exist
+var dummyPassword = []byte("$2a$10$G7hLlAaDzOelI2VPaot.PuZQku2U0Dvxiq8rjo/kUvsuJDZR1ZReG")
+
+type loginRequest struct {
+	MobileNumber string `json:"mobileNumber" validate:"required,mobile"`
+	Password     string `json:"password" validate:"required,maxbytes=72"`
+}
+
+// Login checks mobile number and password and issues tokens
+func (self apiv1) Login(ctx *application.Context) error {
+	request := loginRequest{}
+	if err := ctx.DecodeModel(&request); err != nil {
+		return err
+	}
+
+	user, err := self.Users.FindByMobileNumber(ctx.Request.Context(), request.MobileNumber)
+	if err == repository.ErrUserNotFound {
+		models.User{Password: dummyPassword}.CheckPassword([]byte(request.Password))
+		return application.NewErrUnauthorized()
+	}
+	if err != nil {
+		return err
+	}
+
+	if !user.CheckPassword([]byte(request.Password)) {
+		return application.NewErrUnauthorized()
+	}
+
+	tokens, err := self.Application.Login(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Finish(http.StatusOK, self.Application.NewSession(user, tokens))
+}
+
+// Refresh rotates refresh token and issues new tokens
+func (self apiv1) Refresh(ctx *application.Context) error {
+	user, tokens, err := self.Application.RefreshToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	return ctx.Finish(http.StatusOK, self.Application.NewSession(user, tokens))
+}
+
+// Logout revokes refresh token and clears token cookies
+func (self apiv1) Logout(ctx *application.Context) error {
+	if err := self.Application.Logout(ctx); err != nil {
+		return err
+	}
+
+	return ctx.NoContent()
+}
@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"microtecture/infrastructure/application"
+	"microtecture/usecase/controllers"
+)
+
+type grpcRoot struct {
+	application.GrpcController
+}
+
+// NewGrpc creates and returns grpc controller
+func NewGrpc(c application.GrpcController) controllers.Grpc {
+	return grpcRoot{c}
+}
+
+func (self grpcRoot) GetBase() application.GrpcController {
+	return self.GrpcController
+}
+
+// Check answers grpc health checks by readiness of application
+func (self grpcRoot) Check(
+	ctx context.Context, request *grpc_health_v1.HealthCheckRequest,
+) (*grpc_health_v1.HealthCheckResponse, error) {
+	status := grpc_health_v1.HealthCheckResponse_SERVING
+	if self.Application.Ready(ctx).Status != application.HEALTH_UP {
+		status = grpc_health_v1.HealthCheckResponse_NOT_SERVING
+	}
+
+	return &grpc_health_v1.HealthCheckResponse{Status: status}, nil
+}
+
+// Watch is not supported, clients poll Check
+func (self grpcRoot) Watch(
+	request *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer,
+) error {
+	return status.Error(codes.Unimplemented, "health watch is not supported")
+}
@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"github.com/google/uuid"
+
+	"microtecture/infrastructure/application"
+	"microtecture/usecase/controllers"
+	repository "microtecture/usecase/repositories"
+)
+
+type natsRoot struct {
+	application.NatsController
+	Users repository.UserRepository
+}
+
+// NewNats creates and returns nats controller
+func NewNats(c application.NatsController, users repository.UserRepository) controllers.Nats {
+	return natsRoot{c, users}
+}
+
+func (self natsRoot) GetBase() application.NatsController {
+	return self.NatsController
+}
+
+type getUserRequest struct {
+	Id string `json:"id" validate:"required,uuid"`
+}
+
+// GetUser replies user by its id to other services, password is not replied
+func (self natsRoot) GetUser(ctx *application.MessageContext) error {
+	request := getUserRequest{}
+	if err := ctx.Decode(&request); err != nil {
+		return err
+	}
+
+	// ErrUserNotFound is replied as not found with user_not_found code
+	user, err := self.Users.FindByID(ctx.Context(), uuid.MustParse(request.Id))
+	if err != nil {
+		return err
+	}
+	user.Password = nil
+
+	return ctx.Respond(user)
+}
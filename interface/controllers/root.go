@@ -1,8 +1,12 @@
 package controllers
 
 import (
+	"net/http"
+
+	"microtecture/domain/models"
 	"microtecture/infrastructure/application"
 	"microtecture/usecase/controllers"
+	repository "microtecture/usecase/repositories"
 )
 
 type root struct {
@@ -19,11 +23,55 @@ func (self root) GetBase() application.RestController {
 	return self.RestController
 }
 
+func (self root) GetApiV1() controllers.ApiV1 {
+	return self.ApiV1
+}
+
+// JWKS writes public keys of access tokens as json web key set
+func (self root) JWKS(ctx *application.Context) error {
+	return ctx.Finish(http.StatusOK, self.Application.JWKS())
+}
+
+// Healthz reports that process is up and serving
+func (self root) Healthz(ctx *application.Context) error {
+	ctx.Response.Header().Set("Cache-Control", "no-store")
+	return ctx.Finish(http.StatusOK, application.Health{Status: application.HEALTH_UP})
+}
+
+// Readyz writes result of readiness checks, 503 if a check fails or application is draining
+func (self root) Readyz(ctx *application.Context) error {
+	health := self.Application.Ready(ctx.Request.Context())
+
+	status := http.StatusOK
+	if health.Status != application.HEALTH_UP {
+		status = http.StatusServiceUnavailable
+	}
+
+	ctx.Response.Header().Set("Cache-Control", "no-store")
+	return ctx.Finish(status, health)
+}
+
+// RegisterModels checks validate tags of request models and domain models,
+// so a bad tag fails startup instead of a request
+func RegisterModels() error {
+	return application.RegisterValidation(
+		loginRequest{},
+		getUserRequest{},
+		models.User{},
+		models.Permission{},
+	)
+}
+
 type apiv1 struct {
 	application.RestController
+	Users repository.UserRepository
 }
 
 // NewApiv1Controller creates and returns apiv1 controller
-func NewApiV1(c application.RestController) controllers.ApiV1 {
-	return apiv1{c}
+func NewApiV1(c application.RestController, users repository.UserRepository) controllers.ApiV1 {
+	return apiv1{c, users}
+}
+
+func (self apiv1) GetBase() application.RestController {
+	return self.RestController
 }
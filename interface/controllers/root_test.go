@@ -0,0 +1,11 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/alecthomas/assert"
+)
+
+func TestRegisterModels(t *testing.T) {
+	assert.NoError(t, RegisterModels())
+}
@@ -0,0 +1,12 @@
+package router
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health/grpc_health_v1"
+
+	"microtecture/usecase/controllers"
+)
+
+func RouteGrpc(server *grpc.Server, controller controllers.Grpc) {
+	grpc_health_v1.RegisterHealthServer(server, controller)
+}
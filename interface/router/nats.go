@@ -0,0 +1,9 @@
+package router
+
+import "microtecture/usecase/controllers"
+
+func RouteNats(controller controllers.Nats) error {
+	base := controller.GetBase()
+
+	return base.Subscribe("microtecture.v1.users.get", controller.GetUser)
+}
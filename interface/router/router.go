@@ -1,11 +1,29 @@
 package router
 
 import (
+	"net/http"
+
+	"microtecture/infrastructure/application"
 	"microtecture/usecase/controllers"
 
 	"github.com/julienschmidt/httprouter"
 )
 
 func Route(router *httprouter.Router, controller controllers.Root) {
+	base := controller.GetBase()
+
+	handle(router, http.MethodGet, "/.well-known/jwks.json", base.Handle(controller.JWKS))
+	handle(router, http.MethodGet, "/healthz", base.Handle(controller.Healthz))
+	handle(router, http.MethodGet, "/readyz", base.Handle(controller.Readyz))
+
+	apiv1 := controller.GetApiV1()
+	v1 := apiv1.GetBase()
+	handle(router, http.MethodPost, "/api/v1/auth/login", v1.Handle(apiv1.Login))
+	handle(router, http.MethodPost, "/api/v1/auth/refresh", v1.Handle(apiv1.Refresh))
+	handle(router, http.MethodPost, "/api/v1/auth/logout", v1.Handle(apiv1.Logout))
+}
 
+// handle registers handler of route, its pattern names spans and metrics of requests
+func handle(router *httprouter.Router, method, path string, handler http.Handler) {
+	router.Handler(method, path, application.WithRoute(path, handler))
 }
@@ -1,40 +1,82 @@
 package registry
 
 import (
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+
 	"microtecture/infrastructure/application"
+	"microtecture/infrastructure/client"
 	"microtecture/infrastructure/datastore"
+	"microtecture/infrastructure/messaging"
 	"microtecture/interface/controllers"
 	uc "microtecture/usecase/controllers"
+	repository "microtecture/usecase/repositories"
 )
 
 // Registry interface
 type Registry interface {
 	NewRootController() uc.Root
+	NewGrpcController() uc.Grpc
+	NewNatsController() uc.Nats
+	Client(name string) (*client.Client, error)
 }
 
 type registry struct {
 	controller     application.Controller
 	restController application.RestController
+	grpcController application.GrpcController
+	natsController application.NatsController
+	userRepository repository.UserRepository
+	// clients of other services by their names, Client returns them to controllers that call them
+	clients map[string]*client.Client
 }
 
 // New creates and returns registry
 func New() (Registry, error) {
+	if err := controllers.RegisterModels(); err != nil {
+		return nil, err
+	}
+
 	app, err := application.New()
 	if err != nil {
 		return nil, err
 	}
+	userRepository := datastore.NewUserRepository(app.DBSession)
+	app.Users = userRepository
+	app.RefreshTokens = datastore.NewRefreshTokenRepository(app.DBSession)
+	app.Roles = datastore.NewRoleRepository(app.DBSession)
+	app.Outbox = datastore.NewOutboxRepository(app.DBSession)
+	app.Transactions = datastore.NewTransactor(app.DBSession)
+	for name, check := range healthChecks(app.DBSession, app.Nats) {
+		app.RegisterCheck(name, check)
+	}
 
 	ctrl, err := application.NewController(app)
 	if err != nil {
 		return nil, err
 	}
 	restController := application.NewRestController(ctrl)
+	grpcController := application.NewGrpcController(ctrl)
+	natsController := application.NewNatsController(ctrl)
 
-	return registry{ctrl, restController}, nil
+	// calls carry token of caller, or a service token without roles if they have no caller
+	serviceToken := client.ServiceToken(app, time.Duration(app.Config.JWT.MaxAge)*time.Second)
+	clients, err := client.NewAll(app.Config, client.CallerOrService(serviceToken), app.Discovery)
+	if err != nil {
+		return nil, err
+	}
+
+	return registry{ctrl, restController, grpcController, natsController, userRepository, clients}, nil
 }
 
 // NewTestRegistry creates and return registry for test goals
 func NewTest() (Registry, error) {
+	if err := controllers.RegisterModels(); err != nil {
+		return nil, err
+	}
+
 	app, err := application.New()
 	if err != nil {
 		return nil, err
@@ -45,21 +87,113 @@ func NewTest() (Registry, error) {
 		return nil, err
 	}
 	app.DBSession = *session
+	ur := datastore.NewUserRepository(app.DBSession)
+	app.Users = ur
+	app.RefreshTokens = datastore.NewRefreshTokenRepository(app.DBSession)
+	app.Roles = datastore.NewRoleRepository(app.DBSession)
+	app.Outbox = datastore.NewOutboxRepository(app.DBSession)
+	app.Transactions = datastore.NewTransactor(app.DBSession)
+	for name, check := range healthChecks(app.DBSession, app.Nats) {
+		app.RegisterCheck(name, check)
+	}
 
 	c, err := application.NewController(app)
 	if err != nil {
 		return nil, err
 	}
 	rc := application.NewRestController(c)
+	gc := application.NewGrpcController(c)
+	nc := application.NewNatsController(c)
+
+	// calls carry token of caller, or a service token without roles if they have no caller
+	serviceToken := client.ServiceToken(app, time.Duration(app.Config.JWT.MaxAge)*time.Second)
+	clients, err := client.NewAll(app.Config, client.CallerOrService(serviceToken), app.Discovery)
+	if err != nil {
+		return nil, err
+	}
 
-	return registry{c, rc}, nil
+	return registry{c, rc, gc, nc, ur, clients}, nil
+}
+
+// Client returns client of service name in services of config, pass it to controllers that call
+// the service
+//
+//	users, err := self.Client("users")
+//	apiv1 := controllers.NewApiV1(base, self.userRepository, users)
+func (self registry) Client(name string) (*client.Client, error) {
+	c, ok := self.clients[name]
+	if !ok {
+		return nil, errors.New("service " + name + " is not set in services of config file")
+	}
+
+	return c, nil
+}
+
+// healthChecks returns readiness checks of /readyz, add checks of other dependencies here
+func healthChecks(session datastore.Session, conn *nats.Conn) map[string]application.Check {
+	checks := map[string]application.Check{
+		"postgres":  session.PingSQL,
+		"couchbase": session.PingCouchbase,
+	}
+	if conn != nil {
+		checks["nats"] = messaging.Check(conn)
+	}
+
+	return checks
+}
+
+// middlewares returns middlewares of every route, they run after default middlewares
+// of rest controller and before group and route middlewares
+func (self registry) middlewares() []application.Middleware {
+	return []application.Middleware{}
+}
+
+// apiV1Middlewares returns middlewares of api v1 routes
+func (self registry) apiV1Middlewares() []application.Middleware {
+	return []application.Middleware{}
 }
 
 // NewRootController creates and return root controller
 func (self registry) NewRootController() uc.Root {
-	apiv1 := controllers.NewApiV1(self.restController)
+	base := self.restController.Use(self.middlewares()...)
+
+	apiv1 := controllers.NewApiV1(base.Use(self.apiV1Middlewares()...), self.userRepository)
 
-	root := controllers.NewRoot(self.restController, apiv1)
+	root := controllers.NewRoot(base, apiv1)
 
 	return root
 }
+
+// grpcPolicies returns policies of grpc methods by their full name like /users.v1.Users/Delete,
+// other methods need only a valid access token
+func (self registry) grpcPolicies() map[string][]application.Policy {
+	return map[string][]application.Policy{}
+}
+
+// grpcPublicMethods returns grpc methods that are called without access token
+func (self registry) grpcPublicMethods() []string {
+	return []string{
+		"/grpc.health.v1.Health/Check",
+		"/grpc.health.v1.Health/Watch",
+	}
+}
+
+// NewGrpcController creates and return grpc controller
+func (self registry) NewGrpcController() uc.Grpc {
+	base := self.grpcController.Authorize(self.grpcPolicies(), self.grpcPublicMethods()...)
+
+	return controllers.NewGrpc(base)
+}
+
+// natsMiddlewares returns middlewares of every nats handler, they run after default
+// middlewares of nats controller
+func (self registry) natsMiddlewares() []application.MessageMiddleware {
+	return []application.MessageMiddleware{}
+}
+
+// NewNatsController creates and return nats controller
+func (self registry) NewNatsController() uc.Nats {
+	base := self.natsController.Use(self.natsMiddlewares()...)
+
+	return controllers.NewNats(base, self.userRepository)
+}
@@ -0,0 +1,13 @@
+package controllers
+
+import (
+	"google.golang.org/grpc/health/grpc_health_v1"
+
+	"microtecture/infrastructure/application"
+)
+
+// Grpc is grpc controller interface, services of it are registered on grpc server
+type Grpc interface {
+	GetBase() application.GrpcController
+	grpc_health_v1.HealthServer
+}
@@ -0,0 +1,9 @@
+package controllers
+
+import "microtecture/infrastructure/application"
+
+// Nats is nats controller interface, its handlers are subscribed to subjects
+type Nats interface {
+	GetBase() application.NatsController
+	GetUser(ctx *application.MessageContext) error
+}
@@ -5,7 +5,16 @@ import "microtecture/infrastructure/application"
 // Root is root controller interface
 type Root interface {
 	GetBase() application.RestController
+	GetApiV1() ApiV1
+	JWKS(ctx *application.Context) error
+	Healthz(ctx *application.Context) error
+	Readyz(ctx *application.Context) error
 }
 
 // ApiV1 is api v1 controller interface
-type ApiV1 interface{}
+type ApiV1 interface {
+	GetBase() application.RestController
+	Login(ctx *application.Context) error
+	Refresh(ctx *application.Context) error
+	Logout(ctx *application.Context) error
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"microtecture/domain/models"
+)
+
+// ErrOutboxEventNotFound is returned when no dead event matches the query
+var ErrOutboxEventNotFound = errors.New("outbox event not found")
+
+// Transactor runs functions in a database transaction
+type Transactor interface {
+	// Transaction runs f in a transaction that repositories join when f calls them with its context,
+	// it is committed if f returns nil and rolled back otherwise. nested transactions join outer one.
+	Transaction(ctx context.Context, f func(ctx context.Context) error) error
+}
+
+// OutboxRepository is persistence of events that are published after their transaction commits
+type OutboxRepository interface {
+	// Add inserts event, in transaction of context if it has one
+	Add(ctx context.Context, event *models.OutboxEvent) error
+	// Relay runs f with at most limit due events in order of their sequence in a transaction
+	// that holds lock of relay, so only one relay of every instance runs f at a time.
+	// events whose aggregate has an earlier unpublished event that is dead or not due are not given,
+	// so a dead event holds later events of its aggregate until it is requeued.
+	// it returns false if another relay holds lock.
+	Relay(ctx context.Context, limit int, f func(ctx context.Context, events []models.OutboxEvent) error) (bool, error)
+	// MarkPublished marks events as published
+	MarkPublished(ctx context.Context, ids []uuid.UUID) error
+	// MarkFailed saves attempts, last error, next attempt and dead time of event
+	MarkFailed(ctx context.Context, event *models.OutboxEvent) error
+	// DeletePublished deletes events that are published before time and returns their count
+	DeletePublished(ctx context.Context, before time.Time) (int64, error)
+	// Requeue makes dead event pending again with no attempts
+	Requeue(ctx context.Context, id uuid.UUID) error
+}
@@ -0,0 +1,9 @@
+package repository
+
+import "context"
+
+// RoleRepository is persistence of roles and their permissions
+type RoleRepository interface {
+	// Permissions returns permission names that role grants by its english name
+	Permissions(ctx context.Context, role string) ([]string, error)
+}
@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"microtecture/domain/models"
+)
+
+var (
+	// ErrRefreshTokenNotFound is returned when refresh token is not issued by this service
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	// ErrRefreshTokenReused is returned when refresh token is already rotated
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// RefreshTokenRepository is persistence of issued refresh tokens
+type RefreshTokenRepository interface {
+	// Create inserts refresh token
+	Create(ctx context.Context, token *models.RefreshToken) error
+	// FindByID returns refresh token by its jti
+	FindByID(ctx context.Context, id uuid.UUID) (*models.RefreshToken, error)
+	// Use marks refresh token as rotated, ErrRefreshTokenReused if it was already
+	Use(ctx context.Context, id uuid.UUID) error
+	// RevokeFamily revokes every refresh token rotated from same login
+	RevokeFamily(ctx context.Context, familyID uuid.UUID) error
+	// RevokeUser revokes every refresh token of user
+	RevokeUser(ctx context.Context, userID uuid.UUID) error
+}
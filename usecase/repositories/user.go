@@ -1 +1,39 @@
 package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"microtecture/domain/models"
+)
+
+var (
+	// ErrUserNotFound is returned when no live user matches the query
+	ErrUserNotFound = errors.New("user not found")
+	// ErrDuplicateMobileNumber is returned when mobile number is already registered
+	ErrDuplicateMobileNumber = errors.New("mobile number already exists")
+)
+
+// Page is offset and limit of a list query
+type Page struct {
+	Offset int
+	Limit  int
+}
+
+// UserRepository is persistence of users
+type UserRepository interface {
+	// Create hashes password, assigns id and inserts user
+	Create(ctx context.Context, user *models.User) error
+	// FindByID returns user with group and roles preloaded
+	FindByID(ctx context.Context, id uuid.UUID) (*models.User, error)
+	// FindByMobileNumber returns user with group and roles preloaded
+	FindByMobileNumber(ctx context.Context, mobileNumber string) (*models.User, error)
+	// Update updates non zero fields of user except password
+	Update(ctx context.Context, user *models.User) error
+	// SoftDelete sets deleted time of user
+	SoftDelete(ctx context.Context, id uuid.UUID) error
+	// List returns a page of users and total count of users
+	List(ctx context.Context, page Page) ([]models.User, int, error)
+}